@@ -0,0 +1,43 @@
+// lazysession-bench measures how a lazysession binary copes with a program
+// that prints as fast as it can, and how much CPU it uses while idle:
+//
+//	go build && go run ./cmd/lazysession-bench --binary ./lazysession
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"github.com/jesseduffield/lazysession/pkg/bench"
+)
+
+var (
+	binaryFlag = flag.String("binary", "lazysession", "The lazysession binary to measure")
+	bytesFlag  = flag.Int("bytes", 50*1000*1000, "How many bytes of output to flood the screen with")
+	idleFlag   = flag.Duration("idle", 10*time.Second, "How long to measure idle CPU for")
+)
+
+func main() {
+	flag.Parse()
+
+	binary, err := exec.LookPath(*binaryFlag)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	result, err := bench.Run(bench.Options{
+		Binary: binary,
+		Bytes:  *bytesFlag,
+		Idle:   *idleFlag,
+		Width:  80,
+		Height: 24,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fmt.Println(result)
+}
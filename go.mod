@@ -4,14 +4,15 @@ go 1.13
 
 require (
 	github.com/cloudfoundry/jibber_jabber v0.0.0-20151120183258-bcc4c8345a21
+	github.com/davecgh/go-spew v1.1.1
 	github.com/fatih/color v1.9.0
 	github.com/go-errors/errors v1.0.1
 	github.com/imdario/mergo v0.3.8
 	github.com/jesseduffield/gocui v0.3.1-0.20200205120724-d229cee5e470
 	github.com/jesseduffield/pty v1.2.1
-	github.com/jesseduffield/termbox-go v0.0.0-20200130214842-1d31d1faa3c9 // indirect
+	github.com/jesseduffield/termbox-go v0.0.0-20200130214842-1d31d1faa3c9
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
-	github.com/mattn/go-runewidth v0.0.8 // indirect
+	github.com/mattn/go-runewidth v0.0.8
 	github.com/nicksnyder/go-i18n/v2 v2.0.3
 	github.com/nsf/termbox-go v0.0.0-20200204031403-4d2b513ad8be // indirect
 	github.com/shibukawa/configdir v0.0.0-20170330084843-e180dbdc8da0
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/yaml.v2"
 
 	"github.com/go-errors/errors"
 
 	"github.com/jesseduffield/lazysession/pkg/app"
 	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/jesseduffield/lazysession/pkg/server"
 )
 
 var (
@@ -20,13 +29,47 @@ var (
 	date        string
 	buildSource = "unknown"
 
-	configFlag    = flag.Bool("config", false, "Print the current default config")
-	debuggingFlag = flag.Bool("debug", false, "a boolean")
-	versionFlag   = flag.Bool("v", false, "Print the current version")
+	configFlag     = flag.Bool("config", false, "Print the current default config")
+	debuggingFlag  = flag.Bool("debug", false, "a boolean")
+	versionFlag    = flag.Bool("v", false, "Print the current version")
+	transcriptFlag = flag.String("transcript", "", "Log everything the command prints to the given file")
+	recordFlag     = flag.String("record", "", "Record the session to the given file in asciicast v2 format")
+	nameFlag       = flag.String("name", "", "Name the session, for attaching to it later")
+	noServerFlag   = flag.Bool("no-server", false, "Run the session in this process, so it can't be detached from")
+	profileFlag    = flag.String("p", "", "Run the named profile from the config")
+	envFlag        envFlags
+	shellInitFlag  = flag.String("shell-init", "", "Print a function for bash, zsh or fish that cds to wherever lazysession's shell was when it exited")
 )
 
+func init() {
+	flag.Var(&envFlag, "env", "Add KEY=VALUE to the environment of the commands we run. Can be given more than once.")
+}
+
+// envFlags collects the --env flags
+type envFlags []string
+
+func (e *envFlags) String() string {
+	return strings.Join(*e, " ")
+}
+
+func (e *envFlags) Set(value string) error {
+	if !strings.Contains(value, "=") || strings.HasPrefix(value, "=") {
+		return fmt.Errorf("'%s' should look like KEY=VALUE", value)
+	}
+	*e = append(*e, value)
+	return nil
+}
+
 func main() {
 	flag.Parse()
+
+	// we're the background process that keeps the session alive
+	if socketPath := os.Getenv(server.ServeEnv); socketPath != "" {
+		if err := server.Serve(socketPath, os.Args[1:]); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 	if *versionFlag {
 		log.Fatalf("commit=%s, build date=%s, build source=%s, version=%s, os=%s, arch=%s\n", commit, date, buildSource, version, runtime.GOOS, runtime.GOARCH)
 	}
@@ -36,6 +79,15 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
+	appConfig.TranscriptPath = *transcriptFlag
+	appConfig.RecordPath = *recordFlag
+	appConfig.Profile = *profileFlag
+	appConfig.Env = envFlag
+	// we're the TUI that a server is running. Programs we run don't need to
+	// know that.
+	appConfig.SocketPath = os.Getenv(server.SocketEnv)
+	os.Unsetenv(server.SocketEnv)
+
 	if *configFlag {
 		configContent, err := yaml.Marshal(appConfig)
 		if err != nil {
@@ -45,8 +97,30 @@ func main() {
 
 	app, err := app.NewApp(appConfig)
 
+	if err == nil && *shellInitFlag != "" {
+		script, err := app.ShellInit(*shellInitFlag)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		fmt.Print(script)
+		return
+	}
+
 	if err == nil {
-		err = app.Run()
+		subcommand := flag.Arg(0)
+		if commandSeparated() {
+			subcommand = ""
+		}
+		switch subcommand {
+		case "play":
+			err = play(app, flag.Args()[1:])
+		case "attach":
+			err = attach(appConfig, flag.Args()[1:])
+		case "ls":
+			err = listSessions(appConfig)
+		default:
+			err = run(app, appConfig)
+		}
 	}
 
 	if err != nil {
@@ -55,3 +129,188 @@ func main() {
 		log.Fatal(fmt.Sprintf("%s\n\n%s", app.Tr.ErrorMessage, stackTrace))
 	}
 }
+
+// commandSeparated says whether the command came after a '--', as in
+// `lazysession -- ls`, which is how to run a command that has the same name
+// as one of our subcommands
+func commandSeparated() bool {
+	i := len(os.Args) - flag.NArg() - 1
+	return flag.NArg() > 0 && i > 0 && os.Args[i] == "--"
+}
+
+// play handles `lazysession play [--speed <n>] <file>`
+func play(a *app.App, args []string) error {
+	playFlags := flag.NewFlagSet("play", flag.ExitOnError)
+	speedFlag := playFlags.Float64("speed", 1, "Playback speed multiplier")
+	if err := playFlags.Parse(args); err != nil {
+		return err
+	}
+	if playFlags.NArg() != 1 {
+		log.Fatal("usage: lazysession play [--speed <n>] <file>")
+	}
+
+	return a.Play(playFlags.Arg(0), *speedFlag)
+}
+
+// run starts a server for the session in the background and attaches to it,
+// so that the session survives the terminal going away. If we can't attach
+// because we're not in a terminal, or we're already running under a server,
+// we run the session ourselves, which --no-server also asks for.
+func run(a *app.App, appConfig *config.AppConfig) error {
+	if *noServerFlag || appConfig.SocketPath != "" || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return a.Run()
+	}
+	args := os.Args[1:]
+	if flag.NArg() == 0 && appConfig.Profile == "" {
+		// without profiles there's nothing to run, which the app will say
+		if len(appConfig.UserConfig.Profiles) == 0 {
+			return a.Run()
+		}
+		// the server's session can't ask, since it's only showing the menu
+		// once we've attached, and then it couldn't be detached from
+		profile, err := pickProfile(appConfig.UserConfig.Profiles)
+		if err != nil || profile == "" {
+			return err
+		}
+		appConfig.Profile = profile
+		args = append(args, "-p", profile)
+	}
+	// the server would die before we could see why
+	if _, ok := appConfig.UserConfig.Profiles[appConfig.Profile]; appConfig.Profile != "" && !ok {
+		log.Fatalf("there's no profile called '%s' in the config\n", appConfig.Profile)
+	}
+
+	name := *nameFlag
+	if name == "" {
+		command := appConfig.Profile
+		if command == "" {
+			command = filepath.Base(flag.Arg(0))
+		}
+		name = newSessionName(appConfig.ConfigDir, command)
+	}
+	socketPath := server.SocketPath(appConfig.ConfigDir, name)
+	if err := server.Start(socketPath, args); err != nil {
+		return err
+	}
+	return attachTo(appConfig, name, socketPath)
+}
+
+// pickProfile asks which profile to run, by name or number, returning ""
+// if the user doesn't say
+func pickProfile(profiles map[string]config.ProfileConfig) (string, error) {
+	names := make([]string, 0, len(profiles))
+	width := 0
+	for name := range profiles {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		p := profiles[name]
+		fmt.Printf("%2d) %-*s  %s\n", i+1, width, name, strings.Join(append([]string{p.Command}, p.Args...), " "))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("profile to run: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			return "", nil
+		}
+		if _, ok := profiles[answer]; ok {
+			return answer, nil
+		}
+		if i, convErr := strconv.Atoi(answer); convErr == nil && i >= 1 && i <= len(names) {
+			return names[i-1], nil
+		}
+		if err == io.EOF {
+			return "", nil
+		}
+		fmt.Printf("there's no profile called '%s'\n", answer)
+	}
+}
+
+// newSessionName names a session after its command, numbering it if there's
+// already one by that name
+func newSessionName(configDir string, command string) string {
+	names, err := server.List(configDir)
+	if err != nil {
+		return command
+	}
+	taken := map[string]bool{}
+	for _, name := range names {
+		taken[name] = true
+	}
+
+	name := command
+	for i := 2; taken[name]; i++ {
+		name = command + "-" + strconv.Itoa(i)
+	}
+	return name
+}
+
+// attach handles `lazysession attach [<name>]`. The name can be left out when
+// there's only one session.
+func attach(appConfig *config.AppConfig, args []string) error {
+	if len(args) > 1 {
+		log.Fatal("usage: lazysession attach [<name>]")
+	}
+
+	var name string
+	if len(args) == 1 {
+		name = args[0]
+	} else {
+		names, err := server.List(appConfig.ConfigDir)
+		if err != nil {
+			return err
+		}
+		if len(names) != 1 {
+			log.Fatalf("there are %d sessions, so you'll need to say which one: see 'lazysession ls'", len(names))
+		}
+		name = names[0]
+	}
+
+	return attachTo(appConfig, name, server.SocketPath(appConfig.ConfigDir, name))
+}
+
+func attachTo(appConfig *config.AppConfig, name string, socketPath string) error {
+	reason, err := server.Attach(socketPath)
+	if err != nil {
+		return err
+	}
+	if reason == server.ReasonDetached {
+		fmt.Printf("[detached from %s: 'lazysession attach %s' to get back to it]\n", name, name)
+		return nil
+	}
+
+	// we're the client that saw the session exit, so it's our shell that
+	// should cd to where it was
+	chosenDir := app.ChosenDirPath(appConfig.ConfigDir, socketPath)
+	if path := os.Getenv(app.ChosenDirEnv); path != "" {
+		if err := os.Rename(chosenDir, path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	_ = os.Remove(chosenDir)
+	return nil
+}
+
+// listSessions handles `lazysession ls`
+func listSessions(appConfig *config.AppConfig) error {
+	names, err := server.List(appConfig.ConfigDir)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -3,22 +3,25 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"flag"
 	"io/ioutil"
 	logNative "log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/asciicast"
 	"github.com/jesseduffield/lazysession/pkg/config"
 	"github.com/jesseduffield/lazysession/pkg/i18n"
 	"github.com/jesseduffield/lazysession/pkg/log"
+	"github.com/jesseduffield/lazysession/pkg/procs"
 	"github.com/sirupsen/logrus"
 )
 
 const stateFilename = "state.json"
-const chosenDirFilename = "chosen_dir"
 
 // App holds everything we need to function
 type App struct {
@@ -28,26 +31,86 @@ type App struct {
 	config *config.AppConfig
 	Log    *logrus.Entry
 	Tr     i18n.TranslationSet
-	cmd    *exec.Cmd
 
-	prevWidth  int
-	prevHeight int
-	ptmx       *os.File
-	started    bool
+	// tabs each show one or two sessions. session is the one in the focused
+	// pane of the selected tab.
+	tabs    []*tab
+	tab     *tab
+	session *session
+	// paneArea is the room the selected tab has for its panes
+	paneArea rect
+
+	started  bool
+	copyMode *copyModeState
+	// fullScreen hides the buffer and info views, e.g. while vim is running
+	fullScreen     bool
+	returnToBuffer bool
+
+	menu   *menuState
+	prompt *promptState
+	player *player
+
+	renderLoop *renderLoop
+
+	highlightRules     []highlightRule
+	highlightsDisabled bool
+
+	// gutter is what the gutter next to the main view shows, if anything
+	gutter string
+
+	profiles map[string]*profile
+	// nested is set when we're running inside another lazysession
+	nested bool
+	// pickingProfile is set while we wait for the user to pick a profile to
+	// run, having been started without a command
+	pickingProfile bool
+
+	// processStats says how the focused session's command is doing, for the
+	// right of the info view
+	processStats string
+	// processTree is the command and its descendants, while the process tree
+	// is open
+	processTree []procs.TreeEntry
+	cpuSampler  cpuSampler
+	cpuPercents map[int]float64
+
+	// statusLine is what the info view shows while there's nothing else to
+	// say, if the user has configured one
+	statusLine []statusPart
+	// statusShown is set while the info view is showing the status line, or
+	// whatever's shown in its place, rather than a message
+	statusShown bool
+	// statusLineTicking is set once we're redrawing the status line every so
+	// often
+	statusLineTicking bool
+
+	// bindings are the keybindings we've set, which the help is made from
+	bindings []binding
 }
 
 // State holds the app's state
 type State struct {
-	History      []string `json:"history"`
-	historyIndex int
-	currentLine  string
+	// History is where the buffer history was kept before each program got
+	// its own. It's moved into Histories when we load it.
+	History []string `json:"history,omitempty"`
+	// Histories holds the buffer history for each program we've wrapped
+	Histories map[string][]string `json:"histories"`
+	yanked    string
 }
 
 // Views stores our views
 type Views struct {
-	main   *gocui.View
-	buffer *gocui.View
-	info   *gocui.View
+	main    *gocui.View
+	buffer  *gocui.View
+	info    *gocui.View
+	copy    *gocui.View
+	menu    *gocui.View
+	search  *gocui.View
+	gutter  *gocui.View
+	tabs    *gocui.View
+	prompt  *gocui.View
+	pane    *gocui.View
+	divider *gocui.View
 }
 
 // NewApp returns a new App
@@ -63,21 +126,24 @@ func NewApp(config *config.AppConfig) (*App, error) {
 		config: config,
 		Log:    logger,
 		Tr:     tr,
+		nested: os.Getenv(nestedEnv) != "",
 	}
 
 	return app, nil
 }
 
 func createCmd() (*exec.Cmd, error) {
-	if len(os.Args) == 1 {
-		return nil, errors.New("must supply command as an argument")
+	// flag.Args() rather than os.Args so that our own flags aren't passed along
+	args := flag.Args()
+	if len(args) == 0 {
+		return nil, errors.New("must supply a command as an argument, or a profile from the config with -p")
 	}
 
-	if len(os.Args) == 2 {
-		return exec.Command(os.Args[1]), nil
+	if len(args) == 1 {
+		return exec.Command(args[0]), nil
 	}
 
-	return exec.Command(os.Args[1], os.Args[2:]...), nil
+	return exec.Command(args[0], args[1:]...), nil
 }
 
 // Run runs the app
@@ -89,7 +155,7 @@ func (app *App) Run() error {
 	}
 
 	// remove the previously chosen dir in case we crash and end up cd'ing for no reason
-	if err := app.writeString(chosenDirFilename, ""); err != nil {
+	if err := app.saveChosenDir(""); err != nil {
 		return err
 	}
 
@@ -97,15 +163,121 @@ func (app *App) Run() error {
 		return err
 	}
 
-	// setting initial local state
-	app.state.historyIndex = -1
+	profiles, err := compileProfiles(app.config.UserConfig.Profiles, app.config.UserConfig.Gui.Theme)
+	if err != nil {
+		return err
+	}
+	app.profiles = profiles
+
+	s, err := app.newFirstSession()
+	if err != nil {
+		return err
+	}
+	app.tabs = []*tab{newTab(s)}
+	app.tab = app.tabs[0]
+	app.session = s
+	defer app.closeSessions()
+
+	if !app.pickingProfile {
+		if err := app.prepareFirstSession(s); err != nil {
+			return err
+		}
+	}
+
+	return app.runGui()
+}
+
+// newFirstSession makes the session for the command or profile we were
+// started with. Without either, it's held for the profile the user picks.
+func (app *App) newFirstSession() (*session, error) {
+	name := app.config.Profile
+	if name == "" && len(flag.Args()) == 0 && len(app.profiles) > 0 {
+		app.pickingProfile = true
+		name = app.profileNames()[0]
+	}
+	if name != "" {
+		p, ok := app.profiles[name]
+		if !ok {
+			logNative.Fatalf("there's no profile called '%s' in the config\n", name)
+		}
+		return app.newProfileSession(p)
+	}
 
 	cmd, err := createCmd()
 	if err != nil {
 		logNative.Fatalln(err)
 	}
+	return app.newSession(cmd)
+}
+
+// prepareFirstSession does what needs doing before the first session's
+// command starts, which is where the buffer history from older versions goes,
+// and whether it's logged or recorded
+func (app *App) prepareFirstSession(s *session) error {
+	if len(app.state.History) > 0 {
+		if app.state.Histories == nil {
+			app.state.Histories = map[string][]string{}
+		}
+		namespace := s.historyNamespace
+		app.state.Histories[namespace] = append(app.state.History, app.state.Histories[namespace]...)
+		app.state.History = nil
+	}
 
-	app.cmd = cmd
+	if app.config.TranscriptPath != "" || app.config.UserConfig.Transcript.Enabled {
+		if err := app.startTranscript(s, app.config.TranscriptPath); err != nil {
+			return err
+		}
+	}
+
+	if app.config.RecordPath != "" {
+		recorder, err := asciicast.NewRecorder(app.config.RecordPath, strings.Join(s.cmd.Args, " "), map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  os.Getenv("TERM"),
+		})
+		if err != nil {
+			return err
+		}
+		s.recorder = recorder
+	}
+
+	return nil
+}
+
+// closeSessions cleans up after every session on our way out, waiting for
+// their commands to exit
+func (app *App) closeSessions() {
+	stopped := []<-chan struct{}{}
+	for _, s := range app.sessions() {
+		stopped = append(stopped, app.closeSession(s))
+	}
+	for _, c := range stopped {
+		<-c
+	}
+}
+
+// runGui sets up the gui and blocks until the user quits
+func (app *App) runGui() error {
+	highlightRules, err := compileHighlightRules(app.config.UserConfig.Highlights)
+	if err != nil {
+		return err
+	}
+	app.highlightRules = highlightRules
+
+	if err := validateRestartPolicy(app.config.UserConfig.Restart.Policy); err != nil {
+		return err
+	}
+
+	gutter, err := parseGutterMode(app.config.UserConfig.Gui.Timestamps)
+	if err != nil {
+		return err
+	}
+	app.gutter = gutter
+
+	statusLine, err := parseStatusLine(app.config.UserConfig.Gui.StatusLine)
+	if err != nil {
+		return err
+	}
+	app.statusLine = statusLine
 
 	// might want to make this depent on the TERM env var
 	g, err := gocui.NewGui(gocui.Output256, false, app.Log)
@@ -117,12 +289,24 @@ func (app *App) Run() error {
 	app.g = g
 	app.g.Cursor = true
 	app.g.Mouse = true
-	app.g.SelFgColor = gocui.ColorGreen
-	app.g.SelBgColor = gocui.ColorGreen
+	app.applyTheme(app.session.theme)
 	app.g.SetManagerFunc(app.layout)
-	if err := app.setKeybindings(); err != nil {
+	app.renderLoop = newRenderLoop(app.config.UserConfig.Gui.MaxFPS)
+	bindings, err := app.makeKeybindings(app.config.UserConfig.Keybindings)
+	if err != nil {
+		return err
+	}
+	if err := app.setKeybindings(bindings); err != nil {
 		return err
 	}
+	if app.config.SocketPath != "" {
+		go app.redrawOnAttach()
+	}
+	if app.player == nil {
+		go app.monitorProcesses()
+		app.startStatusLine()
+	}
+	go app.watchConfig()
 
 	if err := app.g.MainLoop(); err != nil && err != gocui.ErrQuit {
 		return err
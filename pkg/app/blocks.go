@@ -0,0 +1,186 @@
+package app
+
+import (
+	"strings"
+)
+
+// outputBlock is the output of a single submitted command. Line numbers index
+// into the screen's lines, scrollback included.
+type outputBlock struct {
+	command    string
+	promptLine int
+	outputLine int
+	// endLine is exclusive, and -1 while the command is still running
+	endLine  int
+	exitCode string
+	folded   bool
+	// outputStarted is false until we know where the command's output begins,
+	// i.e. until we receive an OSC 133;C mark
+	outputStarted bool
+}
+
+func (b *outputBlock) end(lineCount int) int {
+	if b.endLine == -1 || b.endLine > lineCount {
+		return lineCount
+	}
+	return b.endLine
+}
+
+func (b *outputBlock) contains(line int, lineCount int) bool {
+	return line >= b.promptLine && line < b.end(lineCount)
+}
+
+// handleOSC is called with the body of every OSC sequence the child prints
+// that the screen doesn't handle itself, along with where the cursor was
+func (s *session) handleOSC(body string, x int, line int) {
+	params := strings.SplitN(body, ";", 2)
+	switch params[0] {
+	case "133":
+		if len(params) == 2 {
+			s.handleSemanticPrompt(params[1], x, line)
+		}
+	case "7":
+		if len(params) == 2 {
+			s.handleCwdReport(params[1])
+		}
+	}
+}
+
+// handleSemanticPrompt handles the OSC 133 marks that shells like fish, zsh
+// with a suitable prompt, or bash with starship emit around prompts and
+// commands. See https://gitlab.freedesktop.org/Per_Bothner/specifications/blob/master/proposals/semantic-prompts.md
+func (s *session) handleSemanticPrompt(mark string, cx int, cy int) {
+	s.blocksMutex.Lock()
+	defer s.blocksMutex.Unlock()
+
+	s.semanticPrompts = true
+
+	params := strings.Split(mark, ";")
+	switch params[0] {
+	case "A":
+		s.promptLine = cy
+		s.closeCurrentBlock(cy)
+	case "B":
+		s.commandX, s.commandY = cx, cy
+	case "C":
+		if block := s.currentBlock(); block != nil && !block.outputStarted {
+			block.outputLine = cy
+			block.outputStarted = true
+			return
+		}
+		// the command was typed straight into the program rather than via the
+		// buffer, so we'll pull it off the screen
+		command := ""
+		if lines := s.screen.Lines(s.commandY, s.commandY+1); len(lines) == 1 {
+			line := []rune(lines[0].String())
+			if s.commandX < len(line) {
+				command = strings.TrimSpace(string(line[s.commandX:]))
+			}
+		}
+		s.blocks = append(s.blocks, &outputBlock{
+			command:       command,
+			promptLine:    s.promptLine,
+			outputLine:    cy,
+			endLine:       -1,
+			outputStarted: true,
+		})
+	case "D":
+		if block := s.currentBlock(); block != nil {
+			block.endLine = cy
+			if len(params) > 1 {
+				block.exitCode = params[1]
+			}
+		}
+	}
+}
+
+// matchPrompt is called when output arrives. Without OSC 133 marks to go on,
+// a line matching the session's prompt pattern under the cursor means the
+// running command has finished and the program wants another.
+func (s *session) matchPrompt() {
+	if s.promptPattern == nil {
+		return
+	}
+
+	s.blocksMutex.Lock()
+	defer s.blocksMutex.Unlock()
+
+	block := s.currentBlock()
+	if s.semanticPrompts || block == nil {
+		return
+	}
+	cy := s.screen.CursorLine()
+	if cy <= block.promptLine {
+		return
+	}
+	if lines := s.screen.Lines(cy, cy+1); len(lines) == 1 && s.promptPattern.MatchString(lines[0].String()) {
+		block.endLine = cy
+	}
+}
+
+// startBlock is called when we submit a command from the buffer
+func (s *session) startBlock(command string) {
+	s.blocksMutex.Lock()
+	defer s.blocksMutex.Unlock()
+
+	if s.semanticPrompts {
+		// the program will tell us where the output starts
+		s.blocks = append(s.blocks, &outputBlock{
+			command:    command,
+			promptLine: s.promptLine,
+			outputLine: s.promptLine + 1,
+			endLine:    -1,
+		})
+		return
+	}
+
+	// without any marks to go on, we assume the cursor is sitting on the prompt
+	// and the command's output begins on the line below
+	cy := s.screen.CursorLine()
+	s.closeCurrentBlock(cy)
+	s.blocks = append(s.blocks, &outputBlock{
+		command:       command,
+		promptLine:    cy,
+		outputLine:    cy + 1,
+		endLine:       -1,
+		outputStarted: true,
+	})
+}
+
+func (s *session) currentBlock() *outputBlock {
+	if len(s.blocks) == 0 {
+		return nil
+	}
+	block := s.blocks[len(s.blocks)-1]
+	if block.endLine != -1 {
+		return nil
+	}
+	return block
+}
+
+func (s *session) closeCurrentBlock(line int) {
+	if block := s.currentBlock(); block != nil {
+		block.endLine = line
+	}
+}
+
+// blocksSnapshot returns a copy of the blocks so far, safe to use from the gui
+func (s *session) blocksSnapshot() []outputBlock {
+	s.blocksMutex.Lock()
+	defer s.blocksMutex.Unlock()
+
+	snapshot := make([]outputBlock, len(s.blocks))
+	for i, block := range s.blocks {
+		snapshot[i] = *block
+	}
+	return snapshot
+}
+
+func (s *session) setBlockFolded(index int, folded bool) {
+	s.blocksMutex.Lock()
+	defer s.blocksMutex.Unlock()
+
+	if index < len(s.blocks) {
+		s.blocks[index].folded = folded
+	}
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// updateCwd notes where the session's command is, after it's printed
+// something. Where there's a /proc we ask the kernel, otherwise we go by the
+// OSC 7 reports of shells that make them.
+func (s *session) updateCwd() {
+	if s.cmd.Process == nil {
+		return
+	}
+	dir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", s.cmd.Process.Pid))
+	if err != nil {
+		return
+	}
+	s.setCwd(dir)
+}
+
+// handleCwdReport handles an OSC 7 report like 'file://host/home/me', which
+// some shells print with each prompt. A report from another host, e.g. over
+// ssh, is no use to us.
+func (s *session) handleCwdReport(report string) {
+	u, err := url.Parse(report)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return
+	}
+	if hostname, _ := os.Hostname(); u.Host != "" && u.Host != "localhost" && u.Host != hostname {
+		return
+	}
+	s.setCwd(u.Path)
+}
+
+func (s *session) setCwd(dir string) {
+	s.cwdMutex.Lock()
+	defer s.cwdMutex.Unlock()
+	s.cwd = dir
+}
+
+func (s *session) getCwd() string {
+	s.cwdMutex.Lock()
+	defer s.cwdMutex.Unlock()
+	return s.cwd
+}
+
+// ChosenDirEnv is set by the function from --shell-init to the file it'll
+// read the directory to cd to from. The file's named after the shell's pid,
+// so that shells don't take each other's.
+const ChosenDirEnv = "LAZYSESSION_CHOSEN_DIR"
+
+// chosenDirsDirname is the folder of the config directory that the chosen
+// dir files go in
+const chosenDirsDirname = "chosen_dirs"
+
+// ChosenDirPath is where a session running under the server at the socket
+// path leaves its working directory. Whichever client sees the session exit
+// moves it to the file its shell function reads.
+func ChosenDirPath(configDir string, socketPath string) string {
+	name := strings.TrimSuffix(filepath.Base(socketPath), filepath.Ext(socketPath))
+	return filepath.Join(configDir, chosenDirsDirname, name)
+}
+
+// chosenDirPath is where we leave the working directory when we exit: the
+// session's own file under a server, since the client that started the
+// server may not be the one attached when it exits, and otherwise the file
+// the shell function asked for, if any
+func (app *App) chosenDirPath() string {
+	if app.config.SocketPath != "" {
+		return ChosenDirPath(app.config.ConfigDir, app.config.SocketPath)
+	}
+	return os.Getenv(ChosenDirEnv)
+}
+
+// writeChosenDir leaves the focused session's working directory where the
+// function from --shell-init can find it and cd there once we've exited
+func (app *App) writeChosenDir() error {
+	if app.session == nil {
+		return nil
+	}
+	return app.saveChosenDir(app.session.getCwd())
+}
+
+func (app *App) saveChosenDir(dir string) error {
+	path := app.chosenDirPath()
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(dir), 0644)
+}
+
+// ShellInit returns a function for the given shell that wraps lazysession,
+// cd'ing to wherever the shell it ran was when it exited. It goes in the
+// shell's rc file, e.g. `eval "$(lazysession --shell-init bash)"`.
+func (app *App) ShellInit(shell string) (string, error) {
+	dir := filepath.Join(app.config.ConfigDir, chosenDirsDirname)
+
+	switch shell {
+	case "bash", "zsh":
+		return fmt.Sprintf(`lazysession() {
+	local file=%[1]s/shell-$$
+	%[2]s="$file" command lazysession "$@"
+	local ret=$?
+	local dir
+	dir="$(cat "$file" 2>/dev/null)"
+	rm -f "$file"
+	if [ -n "$dir" ] && [ "$dir" != "$PWD" ]; then
+		cd -- "$dir"
+	fi
+	return $ret
+}
+`, quoteShellArg(dir, `'\''`), ChosenDirEnv), nil
+	case "fish":
+		return fmt.Sprintf(`function lazysession
+	set -l file %[1]s/shell-$fish_pid
+	env %[2]s=$file lazysession $argv
+	set -l ret $status
+	set -l dir (cat $file 2>/dev/null)
+	rm -f $file
+	if test -n "$dir"; and test "$dir" != "$PWD"
+		cd $dir
+	end
+	return $ret
+end
+`, quoteShellArg(dir, `\'`), ChosenDirEnv), nil
+	default:
+		return "", fmt.Errorf("can't make a function for '%s': it needs to be bash, zsh or fish", shell)
+	}
+}
+
+// quoteShellArg single quotes the arg, escaping any single quotes in it the
+// way the shell wants
+func quoteShellArg(arg string, escapedQuote string) string {
+	return "'" + strings.Replace(arg, "'", escapedQuote, -1) + "'"
+}
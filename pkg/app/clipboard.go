@@ -0,0 +1,68 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	clipboardAuto    = "auto"
+	clipboardOSC52   = "osc52"
+	clipboardCommand = "command"
+	clipboardTmux    = "tmux"
+)
+
+// copyToClipboard sends the given text to whichever clipboard the user has
+// configured. The text is also kept around so that it can be inserted into the
+// buffer view.
+func (app *App) copyToClipboard(text string) error {
+	app.state.yanked = text
+
+	clipboardConfig := app.config.UserConfig.Clipboard
+
+	method := clipboardConfig.Method
+	if method == "" || method == clipboardAuto {
+		switch {
+		case os.Getenv("TMUX") != "":
+			method = clipboardTmux
+		case clipboardConfig.Command != "":
+			method = clipboardCommand
+		default:
+			method = clipboardOSC52
+		}
+	}
+
+	switch method {
+	case clipboardOSC52:
+		return copyWithOSC52(text)
+	case clipboardCommand:
+		if clipboardConfig.Command == "" {
+			return errors.New(app.Tr.NoClipboardCommand)
+		}
+		return copyWithCommand(exec.Command("sh", "-c", clipboardConfig.Command), text)
+	case clipboardTmux:
+		return copyWithCommand(exec.Command("tmux", "load-buffer", "-"), text)
+	default:
+		return fmt.Errorf(app.Tr.UnknownClipboardMethod, method)
+	}
+}
+
+// copyWithOSC52 asks the host terminal to set its clipboard. This works over
+// ssh as long as the terminal supports it.
+func copyWithOSC52(text string) error {
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	_, err := os.Stdout.WriteString("\x1b]52;c;" + encoded + "\a")
+	return err
+}
+
+func copyWithCommand(cmd *exec.Cmd, text string) error {
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
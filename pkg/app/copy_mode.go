@@ -0,0 +1,570 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+type selectionMode int
+
+const (
+	selectNone selectionMode = iota
+	selectChar
+	selectLine
+	selectBlock
+)
+
+// copyModeState holds a snapshot of the main view's scrollback along with a
+// cursor that can be moved around it, tmux-style. Coordinates are in runes
+// within lines.
+type copyModeState struct {
+	lines  [][]rune
+	blocks []outputBlock
+	// rows holds the index of each line that isn't hidden inside a folded block
+	rows      []int
+	x, y      int
+	top       int // index into rows
+	anchorX   int
+	anchorY   int
+	selection selectionMode
+	prevView  string
+	// searching is true while we're prompting for a search
+	searching      bool
+	searchQuery    string
+	searchBackward bool
+}
+
+func (app *App) enterCopyMode() error {
+	if app.copyMode != nil {
+		return nil
+	}
+
+	scrollback, first := app.scrollbackLines()
+	lines := make([][]rune, 0, len(scrollback))
+	for _, line := range scrollback {
+		lines = append(lines, []rune(strings.TrimRight(line, " ")))
+	}
+	if len(lines) == 0 {
+		lines = [][]rune{{}}
+	}
+
+	// blocks are numbered the way the screen numbers lines, so they need
+	// shifting to line up with ours. Blocks that fell out of the scrollback end
+	// up with negative lines.
+	blocks := app.session.blocksSnapshot()
+	for i := range blocks {
+		blocks[i].promptLine -= first
+		blocks[i].outputLine -= first
+		if blocks[i].endLine != -1 {
+			blocks[i].endLine -= first
+		}
+	}
+
+	prevView := "main"
+	if currentView := app.g.CurrentView(); currentView != nil {
+		prevView = currentView.Name()
+	}
+
+	app.copyMode = &copyModeState{
+		lines:    lines,
+		blocks:   blocks,
+		y:        len(lines) - 1,
+		prevView: prevView,
+	}
+	app.copyMode.refreshRows()
+
+	if err := app.layoutCopyView(app.g); err != nil {
+		return err
+	}
+	if _, err := app.g.SetCurrentView("copy"); err != nil {
+		return err
+	}
+
+	app.renderInfo(utils.ColoredString(app.copyModeInfo(), color.FgYellow))
+
+	return app.renderCopyMode()
+}
+
+// copyModeInfo says what the keys in copy mode are
+func (app *App) copyModeInfo() string {
+	return fmt.Sprintf(app.Tr.CopyModeInfo,
+		app.keyHint("copy", "left", "down", "up", "right"),
+		app.keyHint("copy", "selectChars", "selectLines", "selectBlock"),
+		app.keyHint("copy", "yank"),
+		app.keyHint("copy", "prevCommand", "nextCommand"),
+		app.keyHint("copy", "fold"),
+		app.keyHint("copy", "yankOutput", "yankCommand"),
+		app.keyHint("copy", "search"),
+		app.keyHint("global", "help"),
+		app.keyHint("copy", "exit"),
+	)
+}
+
+func (app *App) exitCopyMode() error {
+	if app.copyMode == nil {
+		return nil
+	}
+
+	prevView := app.copyMode.prevView
+	app.copyMode = nil
+	app.views.copy = nil
+
+	if err := app.g.DeleteView("copy"); err != nil {
+		return err
+	}
+	if _, err := app.g.SetCurrentView(prevView); err != nil {
+		return err
+	}
+
+	app.renderDefaultInfo()
+	return nil
+}
+
+// layoutCopyView places the copy view directly on top of the main view
+func (app *App) layoutCopyView(g *gocui.Gui) error {
+	if app.copyMode == nil {
+		return nil
+	}
+
+	x0, y0, x1, y1, err := g.ViewPosition("main")
+	if err != nil {
+		return err
+	}
+
+	v, err := g.SetView("copy", x0, y0, x1, y1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = false
+		v.Wrap = false
+		v.Autoscroll = false
+		app.views.copy = v
+	}
+
+	_, err = g.SetViewOnTop("copy")
+	return err
+}
+
+func (app *App) renderCopyMode() error {
+	cm := app.copyMode
+	v := app.views.copy
+	if cm == nil || v == nil {
+		return nil
+	}
+
+	_, height := v.Size()
+	if height < 1 {
+		height = 1
+	}
+	cursorRow := cm.rowIndex(cm.y)
+	if cursorRow < cm.top {
+		cm.top = cursorRow
+	}
+	if cursorRow >= cm.top+height {
+		cm.top = cursorRow - height + 1
+	}
+
+	v.Clear()
+	for row := 0; row < height && cm.top+row < len(cm.rows); row++ {
+		y := cm.rows[cm.top+row]
+		line := cm.renderLine(y)
+		if block := cm.foldedBlockAt(y); block != nil {
+			line += utils.ColoredString(fmt.Sprintf(" [+%d lines]", block.end(len(cm.lines))-block.outputLine), color.FgCyan)
+		}
+		// positioning each line explicitly means a line filling the whole width
+		// doesn't push the following lines down
+		fmt.Fprintf(v, "\x1b[%d;1H%s", row+1, line)
+	}
+	v.Autoscroll = false
+
+	return v.SetCursor(cm.x, cursorRow-cm.top)
+}
+
+// refreshRows works out which lines are visible given the folded blocks, and
+// moves the cursor off any line that's been hidden
+func (cm *copyModeState) refreshRows() {
+	hidden := make([]bool, len(cm.lines))
+	for _, block := range cm.blocks {
+		// a block is folded onto its prompt, so one whose prompt is out of the
+		// snapshot stays open
+		if !block.folded || block.promptLine < 0 {
+			continue
+		}
+		for y := block.outputLine; y < block.end(len(cm.lines)); y++ {
+			if y >= 0 && y != block.promptLine {
+				hidden[y] = true
+			}
+		}
+	}
+
+	cm.rows = cm.rows[:0]
+	for y := range cm.lines {
+		if !hidden[y] {
+			cm.rows = append(cm.rows, y)
+		}
+	}
+	if len(cm.rows) == 0 {
+		// there's always something to put the cursor on
+		for y := range cm.lines {
+			cm.rows = append(cm.rows, y)
+		}
+	}
+
+	// the nearest visible line above is the prompt of the block the cursor
+	// was folded into
+	if index := sort.SearchInts(cm.rows, cm.y); index == len(cm.rows) || cm.rows[index] != cm.y {
+		if index > 0 {
+			index--
+		}
+		cm.y = cm.rows[index]
+	}
+	cm.clampX()
+}
+
+// rowIndex returns the index of the visible row holding the given line
+func (cm *copyModeState) rowIndex(y int) int {
+	index := sort.SearchInts(cm.rows, y)
+	if index >= len(cm.rows) {
+		return len(cm.rows) - 1
+	}
+	return index
+}
+
+// foldedBlockAt returns the folded block whose prompt is on the given line
+func (cm *copyModeState) foldedBlockAt(y int) *outputBlock {
+	for i := range cm.blocks {
+		if cm.blocks[i].folded && cm.blocks[i].promptLine == y {
+			return &cm.blocks[i]
+		}
+	}
+	return nil
+}
+
+// blockIndexAt returns the index of the block containing the given line, or -1
+func (cm *copyModeState) blockIndexAt(y int) int {
+	for i := len(cm.blocks) - 1; i >= 0; i-- {
+		if cm.blocks[i].contains(y, len(cm.lines)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// blockOutput returns the output of the given block, minus trailing blank lines
+func (cm *copyModeState) blockOutput(block outputBlock) string {
+	lines := []string{}
+	for y := block.outputLine; y < block.end(len(cm.lines)); y++ {
+		if y >= 0 {
+			lines = append(lines, string(cm.lines[y]))
+		}
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n ")
+}
+
+// renderLine returns the given line with the selected portion in reverse video
+func (cm *copyModeState) renderLine(y int) string {
+	line := cm.lines[y]
+	start, end, ok := cm.selectedRange(y)
+	if !ok {
+		return string(line)
+	}
+
+	if end > len(line) {
+		end = len(line)
+	}
+	if start > end {
+		start = end
+	}
+
+	selected := string(line[start:end])
+	if selected == "" {
+		selected = " "
+	}
+
+	return string(line[:start]) + "\x1b[7m" + selected + "\x1b[0m" + string(line[end:])
+}
+
+// selectedRange returns the half-open range of runes that are selected on the
+// given line, if any
+func (cm *copyModeState) selectedRange(y int) (int, int, bool) {
+	if cm.selection == selectNone {
+		return 0, 0, false
+	}
+
+	startX, startY, endX, endY := cm.anchorX, cm.anchorY, cm.x, cm.y
+	if startY > endY || (startY == endY && startX > endX) {
+		startX, startY, endX, endY = endX, endY, startX, startY
+	}
+	if y < startY || y > endY {
+		return 0, 0, false
+	}
+
+	lineLength := len(cm.lines[y])
+
+	switch cm.selection {
+	case selectLine:
+		return 0, lineLength, true
+	case selectBlock:
+		left, right := cm.anchorX, cm.x
+		if left > right {
+			left, right = right, left
+		}
+		return left, right + 1, true
+	default:
+		start := 0
+		if y == startY {
+			start = startX
+		}
+		end := lineLength
+		if y == endY {
+			end = endX + 1
+		}
+		return start, end, true
+	}
+}
+
+// selectedText returns the text that would be yanked. With no selection we
+// yank the line under the cursor.
+func (cm *copyModeState) selectedText() string {
+	if cm.selection == selectNone {
+		return string(cm.lines[cm.y])
+	}
+
+	startY, endY := cm.anchorY, cm.y
+	if startY > endY {
+		startY, endY = endY, startY
+	}
+
+	result := make([]string, 0, endY-startY+1)
+	for y := startY; y <= endY; y++ {
+		line := cm.lines[y]
+		start, end, _ := cm.selectedRange(y)
+		if end > len(line) {
+			end = len(line)
+		}
+		if start > end {
+			start = end
+		}
+		result = append(result, strings.TrimRight(string(line[start:end]), " "))
+	}
+
+	return strings.Join(result, "\n")
+}
+
+func (cm *copyModeState) moveCursor(dx, dy int) {
+	row := cm.rowIndex(cm.y) + dy
+	if row < 0 {
+		row = 0
+	}
+	if row > len(cm.rows)-1 {
+		row = len(cm.rows) - 1
+	}
+	cm.y = cm.rows[row]
+
+	cm.x += dx
+	cm.clampX()
+}
+
+func (cm *copyModeState) clampX() {
+	maxX := len(cm.lines[cm.y]) - 1
+	if cm.selection == selectBlock {
+		// block selections can extend past the end of short lines
+		maxX = cm.x
+	}
+	if cm.x > maxX {
+		cm.x = maxX
+	}
+	if cm.x < 0 {
+		cm.x = 0
+	}
+}
+
+// withCopyMode applies f to the copy mode state and re-renders the copy view
+func (app *App) withCopyMode(f func(cm *copyModeState)) error {
+	if app.copyMode == nil {
+		return nil
+	}
+	f(app.copyMode)
+	return app.renderCopyMode()
+}
+
+func (app *App) copyModePageSize() int {
+	if app.views.copy == nil {
+		return 1
+	}
+	_, height := app.views.copy.Size()
+	if height < 2 {
+		return 1
+	}
+	return height / 2
+}
+
+func (app *App) copyModeUp() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(0, -1) })
+}
+
+func (app *App) copyModeDown() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(0, 1) })
+}
+
+func (app *App) copyModeLeft() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(-1, 0) })
+}
+
+func (app *App) copyModeRight() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(1, 0) })
+}
+
+func (app *App) copyModeHalfPageUp() error {
+	pageSize := app.copyModePageSize()
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(0, -pageSize) })
+}
+
+func (app *App) copyModeHalfPageDown() error {
+	pageSize := app.copyModePageSize()
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(0, pageSize) })
+}
+
+func (app *App) copyModeLineStart() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.x = 0 })
+}
+
+func (app *App) copyModeLineEnd() error {
+	return app.withCopyMode(func(cm *copyModeState) {
+		cm.x = len(cm.lines[cm.y]) - 1
+		cm.clampX()
+	})
+}
+
+func (app *App) copyModeTop() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(0, -len(cm.rows)) })
+}
+
+func (app *App) copyModeBottom() error {
+	return app.withCopyMode(func(cm *copyModeState) { cm.moveCursor(0, len(cm.rows)) })
+}
+
+func (app *App) toggleSelection(mode selectionMode) func() error {
+	return func() error {
+		return app.withCopyMode(func(cm *copyModeState) {
+			if cm.selection == mode {
+				cm.selection = selectNone
+				cm.clampX()
+				return
+			}
+			if cm.selection == selectNone {
+				cm.anchorX, cm.anchorY = cm.x, cm.y
+			}
+			cm.selection = mode
+		})
+	}
+}
+
+func (app *App) copyModePrevBlock() error {
+	return app.withCopyMode(func(cm *copyModeState) {
+		for i := len(cm.blocks) - 1; i >= 0; i-- {
+			if cm.blocks[i].promptLine < cm.y && cm.blocks[i].promptLine >= 0 {
+				cm.y = cm.blocks[i].promptLine
+				cm.x = 0
+				return
+			}
+		}
+	})
+}
+
+func (app *App) copyModeNextBlock() error {
+	return app.withCopyMode(func(cm *copyModeState) {
+		for _, block := range cm.blocks {
+			if block.promptLine > cm.y && block.promptLine < len(cm.lines) {
+				cm.y = block.promptLine
+				cm.x = 0
+				return
+			}
+		}
+	})
+}
+
+func (app *App) toggleBlockFold() error {
+	return app.withCopyMode(func(cm *copyModeState) {
+		index := cm.blockIndexAt(cm.y)
+		// a block whose prompt is out of the snapshot has nowhere to fold onto
+		if index == -1 || (!cm.blocks[index].folded && cm.blocks[index].promptLine < 0) {
+			return
+		}
+		cm.blocks[index].folded = !cm.blocks[index].folded
+		app.session.setBlockFolded(index, cm.blocks[index].folded)
+		cm.refreshRows()
+	})
+}
+
+func (app *App) yankBlockOutput() error {
+	return app.yankBlock(func(cm *copyModeState, block outputBlock) string {
+		return cm.blockOutput(block)
+	})
+}
+
+func (app *App) yankBlockCommand() error {
+	return app.yankBlock(func(cm *copyModeState, block outputBlock) string {
+		return block.command
+	})
+}
+
+func (app *App) yankBlock(getText func(*copyModeState, outputBlock) string) error {
+	cm := app.copyMode
+	if cm == nil {
+		return nil
+	}
+
+	index := cm.blockIndexAt(cm.y)
+	if index == -1 {
+		return nil
+	}
+
+	text := getText(cm, cm.blocks[index])
+	if err := app.exitCopyMode(); err != nil {
+		return err
+	}
+
+	return app.yank(text)
+}
+
+func (app *App) copyModeYank() error {
+	if app.copyMode == nil {
+		return nil
+	}
+
+	text := app.copyMode.selectedText()
+	if err := app.exitCopyMode(); err != nil {
+		return err
+	}
+
+	return app.yank(text)
+}
+
+// yank copies the text to the clipboard and reports back in the info view
+func (app *App) yank(text string) error {
+	if err := app.copyToClipboard(text); err != nil {
+		return app.renderError(err)
+	}
+
+	app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.CopiedToClipboard, len([]rune(text))), color.FgGreen))
+	return nil
+}
+
+// pasteYanked inserts the most recently yanked text into the buffer view
+func (app *App) pasteYanked() error {
+	for _, ch := range app.state.yanked {
+		if ch == '\n' {
+			app.views.buffer.EditNewLine()
+		} else {
+			app.views.buffer.EditWrite(ch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCopyMode makes an app in copy mode over ten lines, with the cursor
+// on the last
+func newTestCopyMode(blocks []outputBlock) *App {
+	lines := make([][]rune, 10)
+	for i := range lines {
+		lines[i] = []rune(fmt.Sprintf("line %d", i))
+	}
+	sessionBlocks := make([]*outputBlock, len(blocks))
+	for i, block := range blocks {
+		block := block
+		sessionBlocks[i] = &block
+	}
+
+	cm := &copyModeState{lines: lines, blocks: blocks, y: len(lines) - 1}
+	cm.refreshRows()
+	return &App{copyMode: cm, session: &session{blocks: sessionBlocks}}
+}
+
+// two commands: one that's finished and one that's still running
+var testBlocks = []outputBlock{
+	{command: "ls", promptLine: 0, outputLine: 1, endLine: 4},
+	{command: "make", promptLine: 4, outputLine: 5, endLine: -1},
+}
+
+func TestCopyModeFold(t *testing.T) {
+	app := newTestCopyMode(append([]outputBlock{}, testBlocks...))
+	cm := app.copyMode
+	cm.y = 6
+
+	assert.NoError(t, app.toggleBlockFold())
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, cm.rows)
+	assert.Equal(t, 4, cm.y, "the cursor goes to the prompt it was folded into")
+	assert.True(t, app.session.blocks[1].folded)
+
+	cm.moveCursor(0, 1)
+	assert.Equal(t, 4, cm.y, "there's nothing below the folded block")
+
+	assert.NoError(t, app.toggleBlockFold())
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, cm.rows)
+	assert.Equal(t, 4, cm.y)
+	assert.False(t, app.session.blocks[1].folded)
+
+	cm.y = 2
+	assert.NoError(t, app.toggleBlockFold())
+	assert.Equal(t, []int{0, 4, 5, 6, 7, 8, 9}, cm.rows)
+	assert.Equal(t, 0, cm.y)
+	cm.moveCursor(0, 1)
+	assert.Equal(t, 4, cm.y, "moving down skips the folded output")
+}
+
+func TestCopyModeJumpBetweenBlocks(t *testing.T) {
+	app := newTestCopyMode(append([]outputBlock{}, testBlocks...))
+	cm := app.copyMode
+
+	for _, expected := range []int{4, 0, 0} {
+		assert.NoError(t, app.copyModePrevBlock())
+		assert.Equal(t, expected, cm.y)
+	}
+	for _, expected := range []int{4, 4} {
+		assert.NoError(t, app.copyModeNextBlock())
+		assert.Equal(t, expected, cm.y)
+	}
+}
+
+// a block whose prompt has scrolled out of the snapshot, and whose output
+// takes up all of it, has nothing to fold onto
+func TestCopyModeFoldWithoutPrompt(t *testing.T) {
+	blocks := []outputBlock{{command: "yes", promptLine: -3, outputLine: -2, endLine: -1, folded: true}}
+	app := newTestCopyMode(blocks)
+	cm := app.copyMode
+	assert.Len(t, cm.rows, 10, "a block folded before its prompt scrolled away stays open")
+	cm.moveCursor(0, -1)
+	assert.Equal(t, 8, cm.y)
+
+	app = newTestCopyMode([]outputBlock{{command: "yes", promptLine: -3, outputLine: -2, endLine: -1}})
+	cm = app.copyMode
+	assert.NoError(t, app.toggleBlockFold())
+	assert.False(t, cm.blocks[0].folded)
+	assert.Len(t, cm.rows, 10)
+	assert.Equal(t, 9, cm.y)
+}
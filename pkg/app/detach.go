@@ -0,0 +1,39 @@
+package app
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/server"
+	"github.com/jesseduffield/termbox-go"
+)
+
+// detach lets go of the terminal we're showing in, leaving the session running
+// in the server for 'lazysession attach' to come back to
+func (app *App) detach() error {
+	if app.config.SocketPath == "" {
+		return nil
+	}
+	if err := server.Detach(app.config.SocketPath); err != nil {
+		return app.renderError(err)
+	}
+	return nil
+}
+
+// redrawOnAttach repaints everything when the server tells us a terminal has
+// attached, which it does with SIGWINCH whether or not the size has changed.
+// The new terminal has none of what we drew before.
+func (app *App) redrawOnAttach() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	for range signals {
+		app.g.Update(func(*gocui.Gui) error {
+			for _, s := range app.sessions() {
+				s.mainView.rendered = false
+			}
+			return termbox.Sync()
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// childTerm is the terminal we tell programs they're running in. The main
+// view draws in 256 colours, which is also why we take COLORTERM away: it
+// would have programs send 24 bit colours that we'd only approximate.
+const childTerm = "xterm-256color"
+
+// env vars we give every command, so that scripts can tell they're running
+// in lazysession and which session they're in
+const (
+	// nestedEnv is set to 1, and is how we know we've been started by
+	// another lazysession
+	nestedEnv = "LAZYSESSION"
+	// nameEnv is the name of the session's tab when the command started
+	nameEnv = "LAZYSESSION_NAME"
+	// socketEnv is the socket of the server we're running under, if any,
+	// which 'lazysession attach' reaches us at
+	socketEnv = "LAZYSESSION_SOCKET"
+)
+
+// commandEnv is the environment the session's command runs in: ours, with
+// TERM set to what the main view understands, the LAZYSESSION variables, and
+// then the changes asked for by the session's profile and --env
+func (app *App) commandEnv(s *session) []string {
+	env := unsetEnv(os.Environ(), "COLORTERM")
+	env = setEnv(env, "TERM", childTerm)
+	env = setEnv(env, nestedEnv, "1")
+	env = setEnv(env, nameEnv, s.name)
+	if app.config.SocketPath != "" {
+		env = setEnv(env, socketEnv, app.config.SocketPath)
+	} else {
+		// it'd belong to a lazysession we're running inside of
+		env = unsetEnv(env, socketEnv)
+	}
+	// a lazysession run inside us has nothing to do with the shell function
+	// that ran us
+	env = unsetEnv(env, ChosenDirEnv)
+
+	if p := s.profile; p != nil {
+		for _, key := range p.config.UnsetEnv {
+			env = unsetEnv(env, key)
+		}
+		keys := make([]string, 0, len(p.config.Env))
+		for key := range p.config.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			env = setEnv(env, key, p.config.Env[key])
+		}
+	}
+
+	for _, entry := range app.config.Env {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			env = setEnv(env, parts[0], parts[1])
+		}
+	}
+	return env
+}
+
+// setEnv sets the variable in the environment, replacing any value it had
+func setEnv(env []string, key string, value string) []string {
+	return append(unsetEnv(env, key), key+"="+value)
+}
+
+func unsetEnv(env []string, key string) []string {
+	result := make([]string, 0, len(env))
+	for _, entry := range env {
+		if !strings.HasPrefix(entry, key+"=") {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandEnv(t *testing.T) {
+	inherited := map[string]string{
+		"TERM":              "screen",
+		"COLORTERM":         "truecolor",
+		socketEnv:           "/outer.sock",
+		ChosenDirEnv:        "/tmp/chosen",
+		"LAZYSESSION_TEST1": "inherited",
+		"LAZYSESSION_TEST2": "inherited",
+	}
+	for key, value := range inherited {
+		old, had := os.LookupEnv(key)
+		assert.NoError(t, os.Setenv(key, value))
+		defer func(key string) {
+			if had {
+				_ = os.Setenv(key, old)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}(key)
+	}
+
+	type scenario struct {
+		name       string
+		socketPath string
+		flags      []string
+		profile    *config.ProfileConfig
+		// expected has what each variable should be set to, with "" for
+		// variables that should be unset
+		expected map[string]string
+	}
+
+	scenarios := []scenario{
+		{
+			"what every command gets",
+			"",
+			nil,
+			nil,
+			map[string]string{
+				"TERM":              childTerm,
+				"COLORTERM":         "",
+				nestedEnv:           "1",
+				nameEnv:             "vim",
+				socketEnv:           "",
+				ChosenDirEnv:        "",
+				"LAZYSESSION_TEST1": "inherited",
+			},
+		},
+		{
+			"under a server",
+			"/sessions/vim.sock",
+			nil,
+			nil,
+			map[string]string{socketEnv: "/sessions/vim.sock"},
+		},
+		{
+			"a profile sets and unsets variables",
+			"",
+			nil,
+			&config.ProfileConfig{
+				Env:      map[string]string{"LAZYSESSION_TEST3": "profile", "TERM": "dumb"},
+				UnsetEnv: []string{"LAZYSESSION_TEST1"},
+			},
+			map[string]string{"LAZYSESSION_TEST1": "", "LAZYSESSION_TEST3": "profile", "TERM": "dumb"},
+		},
+		{
+			"--env goes on top of the profile",
+			"",
+			[]string{"LAZYSESSION_TEST3=flag", "LAZYSESSION_TEST2=a=b"},
+			&config.ProfileConfig{Env: map[string]string{"LAZYSESSION_TEST3": "profile"}},
+			map[string]string{"LAZYSESSION_TEST2": "a=b", "LAZYSESSION_TEST3": "flag"},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := &App{config: &config.AppConfig{SocketPath: s.socketPath, Env: s.flags}}
+			session := &session{name: "vim"}
+			if s.profile != nil {
+				session.profile = &profile{config: *s.profile}
+			}
+
+			env := app.commandEnv(session)
+			values := map[string][]string{}
+			for _, entry := range env {
+				parts := strings.SplitN(entry, "=", 2)
+				values[parts[0]] = append(values[parts[0]], parts[1])
+			}
+			for key, expected := range s.expected {
+				if expected == "" {
+					assert.NotContains(t, values, key)
+					continue
+				}
+				assert.Equal(t, []string{expected}, values[key], key)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+const exportsDirname = "exports"
+
+// exportTimeFormat is how we timestamp lines in exports and transcripts
+const exportTimeFormat = "2006-01-02 15:04:05.000"
+
+const (
+	exportPlain = "txt"
+	exportANSI  = "ansi"
+	exportHTML  = "html"
+)
+
+// scrollbackExport renders the current scrollback in the given format. With
+// timestamps, each line starts with the time it arrived.
+func (app *App) scrollbackExport(format string, timestamps bool) string {
+	var builder strings.Builder
+	if err := app.writeScrollbackExport(&builder, format, timestamps); err != nil {
+		app.Log.Error(err)
+	}
+	return builder.String()
+}
+
+// writeScrollbackExport is like scrollbackExport but writes the export a line
+// at a time, so that a long history never has to be in memory all at once
+func (app *App) writeScrollbackExport(w io.Writer, format string, timestamps bool) error {
+	if format == exportHTML {
+		if _, err := io.WriteString(w, utils.HTMLDocumentHeader(app.session.commandLine())); err != nil {
+			return err
+		}
+	}
+
+	// blank lines are held back until we know they aren't at the end
+	blanks := 0
+	written := false
+	_, err := app.eachHistoryLine(format != exportPlain, func(line terminal.HistoryLine) error {
+		text := line.Text
+		if format == exportPlain {
+			text = strings.TrimRight(utils.Decolorise(text), " ")
+		}
+		// lines without a time were never printed to, so they're blank
+		if timestamps && !line.Time.IsZero() {
+			text = line.Time.Format(exportTimeFormat) + " " + text
+		}
+		if text == "" {
+			blanks++
+			return nil
+		}
+		text = strings.Repeat("\n", blanks) + text + "\n"
+		if format == exportHTML {
+			// each line ends any colours it starts, so it can be converted
+			// on its own
+			text = utils.AnsiToHTML(text)
+		}
+		blanks = 0
+		written = true
+		_, err := io.WriteString(w, text)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	end := ""
+	if !written {
+		end = "\n"
+	}
+	if format == exportHTML {
+		end += utils.HTMLDocumentFooter
+	}
+	_, err = io.WriteString(w, end)
+	return err
+}
+
+// exportScrollback writes the scrollback to a new file in the config
+// directory and tells the user where it went
+func (app *App) exportScrollback(format string, timestamps bool) error {
+	dir := filepath.Join(app.config.ConfigDir, exportsDirname)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return app.renderError(err)
+	}
+
+	file, path, err := createNewFile(dir, time.Now().Format("2006-01-02_15-04-05"), "."+format)
+	if err != nil {
+		return app.renderError(err)
+	}
+	writer := bufio.NewWriter(file)
+	err = app.writeScrollbackExport(writer, format, timestamps)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return app.renderError(err)
+	}
+
+	app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.ScrollbackExported, path), color.FgGreen))
+	return nil
+}
+
+// createNewFile creates a file in the directory named name+ext, numbering it
+// like name-2+ext if that's taken, e.g. by an export made in the same second
+func createNewFile(dir string, name string, ext string) (*os.File, string, error) {
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, name+ext)
+		if i > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, ext))
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		return file, path, err
+	}
+}
+
+func (app *App) openExportMenu() error {
+	items := []*menuItem{}
+	for _, option := range []struct {
+		label      string
+		format     string
+		timestamps bool
+	}{
+		{label: app.Tr.ExportPlain, format: exportPlain},
+		{label: app.Tr.ExportPlainTimestamps, format: exportPlain, timestamps: true},
+		{label: app.Tr.ExportANSI, format: exportANSI},
+		{label: app.Tr.ExportHTML, format: exportHTML},
+	} {
+		option := option
+		items = append(items, &menuItem{
+			label:   option.label,
+			onPress: func() error { return app.exportScrollback(option.format, option.timestamps) },
+		})
+	}
+
+	return app.createMenu(app.Tr.ExportScrollbackTitle, items)
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrollbackExport(t *testing.T) {
+	type scenario struct {
+		name     string
+		output   string
+		format   string
+		expected string
+	}
+
+	scenarios := []scenario{
+		{"nothing written", "", exportPlain, "\n"},
+		{"blank lines are only dropped at the end", "a\r\n\r\nb  \r\n", exportPlain, "a\n\nb\n"},
+		{"colours are dropped from plain text", "\x1b[31mred\x1b[m", exportPlain, "red\n"},
+		{"colours are kept in ansi", "\x1b[31mred\x1b[m", exportANSI, "\x1b[0m\x1b[38;5;1mred\x1b[0m\n"},
+		{
+			"html is a page",
+			"\x1b[31mred\x1b[m\r\n\r\n<b>",
+			exportHTML,
+			"<title>make &amp;&amp; test</title>",
+		},
+		{
+			"html lines are converted one at a time",
+			"\x1b[31mred\x1b[m\r\n\r\n<b>",
+			exportHTML,
+			"<pre><span style=\"color:#cd0000\">red</span>\n\n&lt;b&gt;\n</pre>",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			session := &session{recordedCommand: "make && test", screen: terminal.New(10, 5)}
+			_, _ = session.screen.Write([]byte(s.output))
+			app := &App{session: session}
+			if s.format == exportHTML {
+				assert.Contains(t, app.scrollbackExport(s.format, false), s.expected)
+				return
+			}
+			assert.Equal(t, s.expected, app.scrollbackExport(s.format, false))
+		})
+	}
+}
+
+func TestCreateNewFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exports")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, expected := range []string{"export.txt", "export-2.txt", "export-3.txt"} {
+		file, path, err := createNewFile(dir, "export", ".txt")
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, expected), path)
+		_, _ = file.WriteString(expected)
+		assert.NoError(t, file.Close())
+	}
+
+	// nothing was overwritten
+	content, err := ioutil.ReadFile(filepath.Join(dir, "export.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "export.txt", string(content))
+
+	_, _, err = createNewFile(filepath.Join(dir, "missing"), "export", ".txt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package app
+
+import "github.com/jesseduffield/gocui"
+
+// onAltScreen is called when the program switches to or from the alternate
+// screen. Programs like vim and less use it, and want the whole terminal.
+// Sessions in other tabs get it when we switch to them.
+func (app *App) onAltScreen(s *session, active bool) {
+	app.g.Update(func(*gocui.Gui) error {
+		if s != app.session {
+			return nil
+		}
+		return app.setFullScreen(active)
+	})
+}
+
+// setFullScreen gives the whole terminal to the main view, or gives the buffer
+// and info views their rows back. The pty is resized to match in layout.
+func (app *App) setFullScreen(fullScreen bool) error {
+	if app.fullScreen == fullScreen {
+		return nil
+	}
+	app.fullScreen = fullScreen
+	app.session.mainView.scrollOffset = 0
+
+	if fullScreen {
+		// the buffer is about to disappear so we'll type straight into the program
+		if currentView := app.g.CurrentView(); currentView != nil && currentView == app.views.buffer {
+			app.returnToBuffer = true
+			if _, err := app.g.SetCurrentView("main"); err != nil {
+				return err
+			}
+			return app.reportFocus(true)
+		}
+		return nil
+	}
+
+	if app.returnToBuffer {
+		app.returnToBuffer = false
+		if app.g.CurrentView() == app.views.main {
+			if _, err := app.g.SetCurrentView("buffer"); err != nil {
+				return err
+			}
+			return app.reportFocus(false)
+		}
+	}
+	return nil
+}
+
+func (app *App) toggleFullScreen() error {
+	return app.setFullScreen(!app.fullScreen)
+}
@@ -2,106 +2,240 @@ package app
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/jesseduffield/lazysession/pkg/utils"
 )
 
 func (app *App) quit() error {
 	app.saveState()
+	if err := app.writeChosenDir(); err != nil {
+		app.Log.Error(err)
+	}
 	return gocui.ErrQuit
 }
 
+// quitIfExited quits if there's nothing left running in the focused pane, or
+// we're only playing back a recording
+func (app *App) quitIfExited() error {
+	if !app.session.exited && app.player == nil {
+		return nil
+	}
+	return app.quit()
+}
+
+// sendControlKey types the control key into the program, as if it had been
+// pressed there. Unless the program has asked for raw input, its terminal
+// turns Ctrl-C, Ctrl-Z and Ctrl-\ into SIGINT, SIGTSTP and SIGQUIT for
+// whichever process is in the foreground, and Ctrl-D into an end of file.
+// unlessTyping does the action while the buffer's empty. Otherwise the
+// character bound to it is typed, as it would have been without the binding.
+func (app *App) unlessTyping(ch rune, handler func() error) func() error {
+	return func() error {
+		if app.views.buffer.Buffer() != "" {
+			app.views.buffer.EditWrite(ch)
+			return nil
+		}
+		return handler()
+	}
+}
+
+func (app *App) sendControlKey(key gocui.Key) func() error {
+	return func() error {
+		s := app.session
+		if s.stdin == nil || s.exited {
+			return nil
+		}
+		if _, err := s.stdin.Write([]byte{byte(key)}); err != nil {
+			app.Log.Error(err)
+		}
+		return nil
+	}
+}
+
 func (app *App) update(f func() error) {
 	app.g.Update(func(*gocui.Gui) error {
 		return f()
 	})
 }
 
+// renderInfo replaces the contents of the info view with the given message
+func (app *App) renderInfo(message string) {
+	app.views.info.Clear()
+	fmt.Fprint(app.views.info, message)
+	app.statusShown = false
+}
+
+// renderError shows the error in the info view. It returns nil so that
+// handlers can use it to report errors without quitting.
+func (app *App) renderError(err error) error {
+	app.renderInfo(utils.ColoredString(err.Error(), color.FgRed))
+	return nil
+}
+
+func (app *App) renderDefaultInfo() {
+	if s := app.session; s.exited {
+		message := app.exitMessage(s) + ", " + fmt.Sprintf(app.Tr.RestartOrQuit, app.keyHint("global", "restart"), app.keyHint("global", "quitIfExited"))
+		if s.restartTimer != nil {
+			message = app.exitMessage(s) + ", " + fmt.Sprintf(app.Tr.RestartingIn, time.Until(s.restartAt).Round(time.Second))
+		}
+		app.renderInfo(utils.ColoredString(message, color.FgGreen))
+		return
+	}
+	defer func() { app.statusShown = true }()
+	if len(app.statusLine) > 0 && app.player == nil {
+		app.renderStatusLine()
+		return
+	}
+	if app.nested {
+		app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.NestedWarning, app.programKeys()), color.FgYellow))
+		return
+	}
+	app.renderInfo(utils.ColoredStringDirect(fmt.Sprintf(app.Tr.SwitchViewHint, app.keyHint("global", "switchView"), app.keyHint("global", "help")), app.session.theme.optionsText))
+}
+
+// scrollbackLines returns the text of the scrollback followed by the screen,
+// one entry per line, including any lines that have spilled to disk. It also
+// returns the number of the first line, which is what block positions are
+// relative to.
+func (app *App) scrollbackLines() ([]string, int) {
+	lines, first := app.historyLines(false)
+	text := make([]string, len(lines))
+	for i, line := range lines {
+		text[i] = line.Text
+	}
+	return text, first
+}
+
+// historyLines is like scrollbackLines but gives the time each line arrived
+// too. With ansi, lines have SGR sequences for their colours and styles.
+func (app *App) historyLines(ansi bool) ([]terminal.HistoryLine, int) {
+	lines := []terminal.HistoryLine{}
+	first, _ := app.eachHistoryLine(ansi, func(line terminal.HistoryLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, first
+}
+
+// eachHistoryLine hands historyLines' lines to fn one at a time. If the spill
+// file can't be read, we make do with what's in memory.
+func (app *App) eachHistoryLine(ansi bool, fn func(terminal.HistoryLine) error) (int, error) {
+	screen := app.session.screen
+	handed := 0
+	first, err := screen.EachHistoryLine(ansi, func(line terminal.HistoryLine) error {
+		handed++
+		return fn(line)
+	})
+	if err == nil || handed > 0 {
+		return first, err
+	}
+
+	// we can still use what's in memory, as long as fn hasn't been given
+	// any lines yet
+	app.Log.Error(err)
+	first = screen.FirstLine()
+	for _, line := range screen.Lines(first, screen.LineCount()) {
+		text := line.String()
+		if ansi {
+			text = line.ANSI()
+		}
+		if err := fn(terminal.HistoryLine{Text: text, Time: line.Time}); err != nil {
+			return first, err
+		}
+	}
+	return first, nil
+}
+
 func (app *App) switchView() error {
+	if app.views.buffer == nil {
+		return nil
+	}
+	defer app.refreshStatusLine()
+	if app.fullScreen {
+		// the buffer is hidden, so the program gets the tab instead
+		if app.g.CurrentView() == app.views.main && app.views.main.StdinWriter != nil {
+			_, err := app.views.main.StdinWriter.Write([]byte{'\t'})
+			return err
+		}
+		return nil
+	}
 	if app.g.CurrentView() == app.views.main {
-		_, err := app.g.SetCurrentView("buffer")
+		if _, err := app.g.SetCurrentView("buffer"); err != nil {
+			return err
+		}
+		return app.reportFocus(false)
+	}
+	if _, err := app.g.SetCurrentView("main"); err != nil {
 		return err
 	}
-	_, err := app.g.SetCurrentView("main")
-	return err
+	return app.reportFocus(true)
 }
 
 func (app *App) flushBuffer() error {
+	s := app.session
+	// there's nothing to send to before the command has started, or once
+	// it's exited, so the buffer keeps what was typed
+	if s.stdin == nil || s.exited {
+		return nil
+	}
 	buffer := app.views.buffer.Buffer()
 	app.views.buffer.Clear()
-	if len(app.state.History) == 0 || app.state.History[len(app.state.History)-1] != buffer {
-		app.state.History = append(app.state.History, buffer)
-	}
+	app.addToHistory(buffer)
 
-	app.state.historyIndex = -1
-	app.views.main.StdinWriter.Write([]byte(buffer + "\r"))
+	s.historyIndex = -1
+	s.startBlock(buffer)
+	s.lastSubmission = time.Now()
+	if app.gutter == gutterRelative {
+		s.mainView.rendered = false
+	}
+	app.notifySubmitted(s)
+	if s.screen.BracketedPaste() && strings.Contains(buffer, "\n") {
+		// this stops the program from running each line as soon as it arrives
+		buffer = "\x1b[200~" + buffer + "\x1b[201~"
+	}
+	if _, err := s.stdin.Write([]byte(buffer + s.lineTerminator)); err != nil {
+		return app.renderError(err)
+	}
 	return nil
 }
 
 func (app *App) nextHistoryItem() error {
-	if app.state.historyIndex == -1 {
+	s := app.session
+	if s.historyIndex == -1 {
 		return nil
 	}
+	history := app.history()
 	app.views.buffer.Clear()
-	if app.state.historyIndex < len(app.state.History)-1 {
-		app.state.historyIndex++
-		fmt.Fprint(app.views.buffer, app.state.History[app.state.historyIndex])
+	if s.historyIndex < len(history)-1 {
+		s.historyIndex++
+		fmt.Fprint(app.views.buffer, history[s.historyIndex])
 	} else {
-		fmt.Fprint(app.views.buffer, app.state.currentLine)
-		app.state.historyIndex = -1
+		fmt.Fprint(app.views.buffer, s.currentLine)
+		s.historyIndex = -1
 	}
+	app.refreshStatusLine()
 	return nil
 }
 
 func (app *App) prevHistoryItem() error {
-	if app.state.historyIndex == -1 {
-		if len(app.state.History) == 0 {
+	s := app.session
+	history := app.history()
+	if s.historyIndex == -1 {
+		if len(history) == 0 {
 			return nil
 		}
-		app.state.currentLine = app.views.buffer.Buffer()
-		app.state.historyIndex = len(app.state.History) - 1
-	} else if app.state.historyIndex > 0 {
-		app.state.historyIndex--
+		s.currentLine = app.views.buffer.Buffer()
+		s.historyIndex = len(history) - 1
+	} else if s.historyIndex > 0 {
+		s.historyIndex--
 	}
 	app.views.buffer.Clear()
-	fmt.Fprint(app.views.buffer, app.state.History[app.state.historyIndex])
-	return nil
-}
-
-func (app *App) scrollMainDown() error {
-	return app.scrollDownView("main")
-}
-
-func (app *App) scrollMainUp() error {
-	return app.scrollUpView("main")
-}
-
-func (app *App) scrollUpView(viewName string) error {
-	mainView, _ := app.g.View(viewName)
-	mainView.Autoscroll = false
-	ox, oy := mainView.Origin()
-	scrollHeight := 1
-	newOy := oy - scrollHeight
-	if newOy <= 0 {
-		newOy = 0
-	}
-	return mainView.SetOrigin(ox, newOy)
-}
-
-func (app *App) scrollDownView(viewName string) error {
-	mainView, _ := app.g.View(viewName)
-	mainView.Autoscroll = false
-	ox, oy := mainView.Origin()
-	_, sy := mainView.Size()
-	y := oy + sy
-	scrollHeight := 1
-	if y < mainView.LinesHeight()-1 {
-		if err := mainView.SetOrigin(ox, oy+scrollHeight); err != nil {
-			return err
-		}
-	} else {
-		mainView.Autoscroll = true
-	}
-
+	fmt.Fprint(app.views.buffer, history[s.historyIndex])
+	app.refreshStatusLine()
 	return nil
 }
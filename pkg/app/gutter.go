@@ -0,0 +1,119 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// what the gutter next to the main view shows
+const (
+	gutterOff      = "off"
+	gutterAbsolute = "absolute"
+	gutterRelative = "relative"
+)
+
+// gutterModes is the order that toggling the gutter goes through
+var gutterModes = []string{gutterOff, gutterAbsolute, gutterRelative}
+
+// gutterWidth fits '15:04:05.000' or '+1234.567s' with a space after it
+const gutterWidth = 13
+
+func parseGutterMode(mode string) (string, error) {
+	if mode == "" {
+		return gutterOff, nil
+	}
+	for _, known := range gutterModes {
+		if mode == known {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown timestamps setting '%s', expected one of %s", mode, strings.Join(gutterModes, ", "))
+}
+
+// gutterShown says whether the gutter takes up room next to the main view. We
+// leave it out in full screen, where the program is drawing rather than
+// printing lines, and in split tabs, where it would only line up with one
+// pane.
+func (app *App) gutterShown() bool {
+	return app.gutter != gutterOff && !app.fullScreen && !app.tab.split()
+}
+
+func (app *App) layoutGutter(g *gocui.Gui, y0 int, y1 int) error {
+	if !app.gutterShown() {
+		if app.views.gutter != nil {
+			if err := g.DeleteView("gutter"); err != nil {
+				return err
+			}
+			app.views.gutter = nil
+		}
+		return nil
+	}
+
+	v, err := g.SetView("gutter", -1, y0, gutterWidth, y1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = false
+		v.Wrap = false
+		v.Autoscroll = false
+		app.views.gutter = v
+	}
+	return nil
+}
+
+// renderGutter writes a timestamp for each of the lines showing in the main
+// view
+func (app *App) renderGutter(lines []terminal.Line) {
+	v := app.views.gutter
+	if v == nil {
+		return
+	}
+
+	v.Clear()
+	for i, line := range lines {
+		if line.Time.IsZero() {
+			continue
+		}
+		fmt.Fprintf(v, "\x1b[%d;1H%s", i+1, utils.ColoredString(app.formatGutterTime(line.Time), color.FgBlue))
+	}
+}
+
+func (app *App) formatGutterTime(t time.Time) string {
+	if app.gutter == gutterRelative {
+		lastSubmission := app.session.lastSubmission
+		if lastSubmission.IsZero() {
+			return ""
+		}
+		return fmt.Sprintf("%+*.3fs", gutterWidth-2, t.Sub(lastSubmission).Seconds())
+	}
+	return t.Format("15:04:05.000")
+}
+
+// toggleGutter moves on to the next gutter mode
+func (app *App) toggleGutter() error {
+	for i, mode := range gutterModes {
+		if mode == app.gutter {
+			app.gutter = gutterModes[(i+1)%len(gutterModes)]
+			break
+		}
+	}
+	// the gutter is drawn alongside the main view
+	app.session.mainView.rendered = false
+
+	message := app.Tr.TimestampsOff
+	switch app.gutter {
+	case gutterAbsolute:
+		message = app.Tr.TimestampsAbsolute
+	case gutterRelative:
+		message = app.Tr.TimestampsRelative
+	}
+	app.renderInfo(utils.ColoredString(message, color.FgGreen))
+	return nil
+}
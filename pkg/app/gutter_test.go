@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGutterMode(t *testing.T) {
+	type scenario struct {
+		input         string
+		expected      string
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"", gutterOff, ""},
+		{"off", gutterOff, ""},
+		{"absolute", gutterAbsolute, ""},
+		{"relative", gutterRelative, ""},
+		{"Absolute", "", "unknown timestamps setting 'Absolute', expected one of off, absolute, relative"},
+		{"on", "", "unknown timestamps setting 'on', expected one of off, absolute, relative"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.input, func(t *testing.T) {
+			mode, err := parseGutterMode(s.input)
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, s.expected, mode)
+		})
+	}
+}
+
+func TestFormatGutterTime(t *testing.T) {
+	submitted := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+
+	type scenario struct {
+		name           string
+		mode           string
+		lastSubmission time.Time
+		time           time.Time
+		expected       string
+	}
+
+	scenarios := []scenario{
+		{"absolute", gutterAbsolute, submitted, submitted.Add(1500 * time.Millisecond), "12:00:01.500"},
+		{"after a submission", gutterRelative, submitted, submitted.Add(1500 * time.Millisecond), "     +1.500s"},
+		{"before a submission", gutterRelative, submitted, submitted.Add(-62 * time.Second), "    -62.000s"},
+		{"a long while after", gutterRelative, submitted, submitted.Add(1234567 * time.Millisecond), "  +1234.567s"},
+		{"nothing submitted yet", gutterRelative, time.Time{}, submitted, ""},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := &App{gutter: s.mode, session: &session{lastSubmission: s.lastSubmission}}
+			label := app.formatGutterTime(s.time)
+			assert.Equal(t, s.expected, label)
+			assert.True(t, len(label) < gutterWidth)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// helpEntry is a line of the help: what some keys do
+type helpEntry struct {
+	keys        []string
+	description string
+	action      string
+}
+
+// openHelp lists what every key does, grouped by the section of the
+// keybindings config it's in, which is also the view it works in. It's made
+// from the bindings we've actually set, so it can't go out of date.
+func (app *App) openHelp() error {
+	sections := []string{}
+	entries := map[string][]*helpEntry{}
+	for _, b := range app.bindings {
+		sectionEntries, ok := entries[b.section]
+		if !ok {
+			sections = append(sections, b.section)
+		}
+		var entry *helpEntry
+		for _, e := range sectionEntries {
+			if e.description == b.description && e.action == b.action {
+				entry = e
+			}
+		}
+		if entry == nil {
+			entry = &helpEntry{description: b.description, action: b.action}
+			entries[b.section] = append(sectionEntries, entry)
+		}
+		// global actions are bound in the program's view too
+		name := keyName(b.key, b.modifier)
+		if !containsString(entry.keys, name) {
+			entry.keys = append(entry.keys, name)
+		}
+	}
+
+	keysWidth := 0
+	for _, sectionEntries := range entries {
+		for _, e := range sectionEntries {
+			if width := len(strings.Join(e.keys, ", ")); width > keysWidth {
+				keysWidth = width
+			}
+		}
+	}
+
+	items := []*menuItem{}
+	for _, section := range sections {
+		items = append(items, &menuItem{label: section + ":"})
+		for _, e := range entries[section] {
+			label := fmt.Sprintf("  %-*s  %s", keysWidth, strings.Join(e.keys, ", "), e.description)
+			if e.action != "" {
+				label += " (" + e.action + ")"
+			}
+			items = append(items, &menuItem{label: label})
+		}
+	}
+	return app.createMenu(app.Tr.KeybindingsTitle, items)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// keyHint names the first key of each of a section's actions, for the hints
+// in the info bar, so that they follow the keybindings config. Actions that
+// aren't bound are left out.
+func (app *App) keyHint(section string, actions ...string) string {
+	names := []string{}
+	for _, action := range actions {
+		for _, b := range app.bindings {
+			if b.section == section && b.action == action {
+				names = append(names, keyName(b.key, b.modifier))
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return app.Tr.Unbound
+	}
+	return strings.Join(names, "/")
+}
+
+// programKeys names the keys we take before the program gets them
+func (app *App) programKeys() string {
+	names := []string{}
+	for _, b := range app.bindings {
+		if b.viewName != "main" || b.section == "playback" {
+			continue
+		}
+		if name := keyName(b.key, b.modifier); !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
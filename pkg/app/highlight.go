@@ -0,0 +1,177 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// highlightRule is a config.HighlightRule ready to be applied
+type highlightRule struct {
+	pattern *regexp.Regexp
+	command *regexp.Regexp
+	fg      terminal.Color
+	bg      terminal.Color
+	flags   terminal.Flag
+}
+
+var highlightColors = map[string]terminal.Color{
+	"black":         0,
+	"red":           1,
+	"green":         2,
+	"yellow":        3,
+	"blue":          4,
+	"magenta":       5,
+	"cyan":          6,
+	"white":         7,
+	"brightblack":   8,
+	"brightred":     9,
+	"brightgreen":   10,
+	"brightyellow":  11,
+	"brightblue":    12,
+	"brightmagenta": 13,
+	"brightcyan":    14,
+	"brightwhite":   15,
+}
+
+var highlightAttributes = map[string]terminal.Flag{
+	"bold":          terminal.Bold,
+	"dim":           terminal.Dim,
+	"italic":        terminal.Italic,
+	"underline":     terminal.Underline,
+	"blink":         terminal.Blink,
+	"reverse":       terminal.Reverse,
+	"strikethrough": terminal.Strikethrough,
+}
+
+// compileHighlightRules checks the user's rules and gets them ready to use
+func compileHighlightRules(rules []config.HighlightRule) ([]highlightRule, error) {
+	result := make([]highlightRule, 0, len(rules))
+	for _, rule := range rules {
+		compiled, err := compileHighlightRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("highlight rule '%s': %v", rule.Pattern, err)
+		}
+		result = append(result, compiled)
+	}
+	return result, nil
+}
+
+func compileHighlightRule(rule config.HighlightRule) (highlightRule, error) {
+	result := highlightRule{fg: terminal.DefaultColor, bg: terminal.DefaultColor}
+
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return result, err
+	}
+	result.pattern = pattern
+
+	if rule.Command != "" {
+		command, err := regexp.Compile(rule.Command)
+		if err != nil {
+			return result, err
+		}
+		result.command = command
+	}
+
+	if result.fg, err = parseHighlightColor(rule.Fg); err != nil {
+		return result, err
+	}
+	if result.bg, err = parseHighlightColor(rule.Bg); err != nil {
+		return result, err
+	}
+
+	for _, attribute := range rule.Attributes {
+		flag, ok := highlightAttributes[strings.ToLower(attribute)]
+		if !ok {
+			return result, fmt.Errorf("unknown attribute '%s'", attribute)
+		}
+		result.flags |= flag
+	}
+
+	return result, nil
+}
+
+// parseHighlightColor accepts a colour name, a number in the 256 colour
+// palette or a hex colour. An empty string gives DefaultColor.
+func parseHighlightColor(str string) (terminal.Color, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if str == "" {
+		return terminal.DefaultColor, nil
+	}
+
+	if c, ok := highlightColors[str]; ok {
+		return c, nil
+	}
+
+	if strings.HasPrefix(str, "#") && len(str) == 7 {
+		value, err := strconv.ParseUint(str[1:], 16, 32)
+		if err == nil {
+			return terminal.RGBColor(uint8(value>>16), uint8(value>>8), uint8(value)), nil
+		}
+	}
+
+	if n, err := strconv.Atoi(str); err == nil && n >= 0 && n <= 255 {
+		return terminal.Color(n), nil
+	}
+
+	return terminal.DefaultColor, fmt.Errorf("unknown colour '%s'", str)
+}
+
+// highlightLine applies the rules to a line from the screen. command is what
+// the line is output from, for rules that are scoped to a command.
+func highlightLine(line *terminal.Line, rules []highlightRule, command string) {
+	text := ""
+	var cells []int
+	for _, rule := range rules {
+		if rule.command != nil && !rule.command.MatchString(command) {
+			continue
+		}
+		if cells == nil {
+			text, cells = line.TextWithCells()
+		}
+
+		for _, match := range rule.pattern.FindAllStringIndex(text, -1) {
+			for i := cells[match[0]]; i < cells[match[1]]; i++ {
+				attr := &line.Cells[i].Attr
+				if rule.fg != terminal.DefaultColor {
+					attr.Fg = rule.fg
+				}
+				if rule.bg != terminal.DefaultColor {
+					attr.Bg = rule.bg
+				}
+				attr.Flags |= rule.flags
+			}
+		}
+	}
+}
+
+// highlightCommand returns the command that the given line is output from
+func (s *session) highlightCommand(blocks []outputBlock, lineCount int, line int) string {
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if blocks[i].contains(line, lineCount) && line >= blocks[i].outputLine {
+			return blocks[i].command
+		}
+	}
+	return s.commandLine()
+}
+
+// toggleHighlights turns the highlight rules on and off
+func (app *App) toggleHighlights() error {
+	app.highlightsDisabled = !app.highlightsDisabled
+	// the screen hasn't changed, but what we draw for it has
+	app.session.mainView.rendered = false
+
+	message := app.Tr.HighlightsOn
+	if app.highlightsDisabled {
+		message = app.Tr.HighlightsOff
+	}
+	app.renderInfo(utils.ColoredString(message, color.FgGreen))
+	return nil
+}
@@ -0,0 +1,157 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHighlightColor(t *testing.T) {
+	type scenario struct {
+		input         string
+		expected      terminal.Color
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"", terminal.DefaultColor, ""},
+		{"red", 1, ""},
+		{" BrightCyan ", 14, ""},
+		{"208", 208, ""},
+		{"#FF8000", terminal.RGBColor(0xff, 0x80, 0x00), ""},
+		{"256", terminal.DefaultColor, "unknown colour '256'"},
+		{"-1", terminal.DefaultColor, "unknown colour '-1'"},
+		{"#ff80", terminal.DefaultColor, "unknown colour '#ff80'"},
+		{"#gggggg", terminal.DefaultColor, "unknown colour '#gggggg'"},
+		{"orange", terminal.DefaultColor, "unknown colour 'orange'"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.input, func(t *testing.T) {
+			color, err := parseHighlightColor(s.input)
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, s.expected, color)
+		})
+	}
+}
+
+func TestCompileHighlightRules(t *testing.T) {
+	type scenario struct {
+		name          string
+		rule          config.HighlightRule
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"everything", config.HighlightRule{Pattern: `\bERROR\b`, Command: "^make", Fg: "red", Bg: "#000000", Attributes: []string{"Bold", "underline"}}, ""},
+		{"a bad pattern", config.HighlightRule{Pattern: `(`}, "highlight rule '(': error parsing regexp: missing closing ): `(`"},
+		{"a bad command", config.HighlightRule{Pattern: `x`, Command: `[`}, "highlight rule 'x': error parsing regexp: missing closing ]: `[`"},
+		{"a bad colour", config.HighlightRule{Pattern: `x`, Bg: "beige"}, "highlight rule 'x': unknown colour 'beige'"},
+		{"a bad attribute", config.HighlightRule{Pattern: `x`, Attributes: []string{"bold", "shiny"}}, "highlight rule 'x': unknown attribute 'shiny'"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			rules, err := compileHighlightRules([]config.HighlightRule{s.rule})
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, rules, 1)
+			assert.Equal(t, terminal.Bold|terminal.Underline, rules[0].flags)
+		})
+	}
+}
+
+func TestHighlightLine(t *testing.T) {
+	type scenario struct {
+		name     string
+		text     string
+		rules    []config.HighlightRule
+		command  string
+		expected string
+	}
+
+	// expected marks the cells whose colour a rule changed with 'f' for the
+	// foreground, 'b' for the background and 'x' for both
+	scenarios := []scenario{
+		{
+			"every match",
+			"ERROR one ERROR two",
+			[]config.HighlightRule{{Pattern: `ERROR`, Fg: "red"}},
+			"",
+			"fffff     fffff",
+		},
+		{
+			"rules are applied in order",
+			"WARN ERROR",
+			[]config.HighlightRule{{Pattern: `[A-Z]+`, Fg: "yellow"}, {Pattern: `ERROR`, Bg: "red"}},
+			"",
+			"ffff xxxxx",
+		},
+		{
+			"wide characters take two cells",
+			"日本 ERROR",
+			[]config.HighlightRule{{Pattern: `本|ERROR`, Fg: "red"}},
+			"",
+			"  ff fffff",
+		},
+		{
+			"a rule for another command",
+			"ERROR",
+			[]config.HighlightRule{{Pattern: `ERROR`, Fg: "red", Command: `^make\b`}},
+			"go test",
+			"",
+		},
+		{
+			"a rule for this command",
+			"ERROR",
+			[]config.HighlightRule{{Pattern: `ERROR`, Fg: "red", Command: `^make\b`}},
+			"make all",
+			"fffff",
+		},
+		{
+			"a match of nothing",
+			"abc",
+			[]config.HighlightRule{{Pattern: `x*`, Fg: "red"}},
+			"",
+			"",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			rules, err := compileHighlightRules(s.rules)
+			assert.NoError(t, err)
+			screen := terminal.New(40, 2)
+			_, _ = screen.Write([]byte(s.text))
+			line := screen.Lines(0, 1)[0]
+
+			highlightLine(&line, rules, s.command)
+
+			var marks strings.Builder
+			for _, cell := range line.Cells {
+				fg, bg := cell.Attr.Fg != terminal.DefaultColor, cell.Attr.Bg != terminal.DefaultColor
+				switch {
+				case fg && bg:
+					marks.WriteByte('x')
+				case fg:
+					marks.WriteByte('f')
+				case bg:
+					marks.WriteByte('b')
+				default:
+					marks.WriteByte(' ')
+				}
+			}
+			assert.Equal(t, s.expected, strings.TrimRight(marks.String(), " "))
+		})
+	}
+}
@@ -1,74 +1,372 @@
 package app
 
-import "github.com/jesseduffield/gocui"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/config"
+)
 
 type binding struct {
 	key      interface{}
 	viewName string
 	handler  func() error
 	modifier gocui.Modifier
+	// description says what the binding does, for the help
+	description string
+	// section and action say where the binding is in the keybindings config.
+	// Bindings without an action can't be changed.
+	section string
+	action  string
+}
+
+// action is something the keybindings config can bind keys to
+type action struct {
+	name        string
+	handler     func() error
+	description string
+	// keys are what the action is bound to unless the config says otherwise
+	keys []string
+	// inProgram binds a global action in the program's view too, where
+	// global bindings don't fire. Keys that type a character are left to the
+	// program.
+	inProgram bool
+	// inEmptyBuffer binds a global action's character keys in the buffer too,
+	// where they only do the action while the buffer's empty and are typed
+	// otherwise
+	inEmptyBuffer bool
 }
 
-func (app *App) setKeybindings() error {
-	bindings := []binding{
+// actionSection is a section of the keybindings config
+type actionSection struct {
+	name string
+	// viewName is the view the section's actions are bound in
+	viewName string
+	actions  []action
+	// inactive sections are checked but not bound
+	inactive bool
+}
+
+// editableViews take keys that type a character as typing, so those keys
+// can't be bound in them
+var editableViews = map[string]bool{"buffer": true, "search": true, "prompt": true}
+
+// actionSections lists what can be bound in each section of the keybindings
+// config, along with the default keys
+func (app *App) actionSections() []actionSection {
+	sections := []actionSection{
 		{
-			key:      gocui.MouseWheelDown,
-			handler:  app.scrollMainDown,
-			viewName: "main",
-			modifier: gocui.ModNone,
+			name:     "global",
+			viewName: "",
+			actions: []action{
+				{name: "switchView", keys: []string{"<tab>"}, handler: app.switchView, description: app.Tr.SwitchView, inProgram: true},
+				{name: "help", keys: []string{"<f1>", "?"}, handler: app.openHelp, description: app.Tr.ShowKeybindings, inProgram: true, inEmptyBuffer: true},
+				{name: "export", keys: []string{"<f2>"}, handler: app.openExportMenu, description: app.Tr.ExportScrollback, inProgram: true},
+				{name: "transcript", keys: []string{"<f3>"}, handler: app.toggleTranscript, description: app.Tr.ToggleTranscript, inProgram: true},
+				{name: "highlights", keys: []string{"<f4>"}, handler: app.toggleHighlights, description: app.Tr.ToggleHighlights, inProgram: true},
+				{name: "timestamps", keys: []string{"<f5>"}, handler: app.toggleGutter, description: app.Tr.ToggleTimestamps, inProgram: true},
+				{name: "newTab", keys: []string{"<f6>"}, handler: app.openNewTabPrompt, description: app.Tr.NewTab, inProgram: true},
+				{name: "prevTab", keys: []string{"<f7>"}, handler: app.prevTab, description: app.Tr.PrevTab, inProgram: true},
+				{name: "nextTab", keys: []string{"<f8>"}, handler: app.nextTab, description: app.Tr.NextTab, inProgram: true},
+				{name: "renameTab", keys: []string{"<f9>"}, handler: app.openRenameTabPrompt, description: app.Tr.RenameTab, inProgram: true},
+				{name: "closePane", keys: []string{"<f10>"}, handler: app.closePane, description: app.Tr.ClosePane, inProgram: true},
+				{name: "fullScreen", keys: []string{"<f11>"}, handler: app.toggleFullScreen, description: app.Tr.ToggleFullScreen, inProgram: true},
+				{name: "prefixMenu", keys: []string{"<f12>"}, handler: app.openPrefixMenu, description: app.Tr.OpenPrefixMenu, inProgram: true},
+				{name: "quit", keys: []string{"<c-q>"}, handler: app.quit, description: app.Tr.Quit, inProgram: true},
+				// once the command has exited there's nothing to interrupt, so
+				// quitting can be easier
+				{name: "quitIfExited", keys: []string{"<esc>", "q"}, handler: app.quitIfExited, description: app.Tr.QuitIfExited},
+				// the restart key only does something once the command has
+				// exited, which is also when it stops going to the program
+				{name: "restart", keys: []string{"r"}, handler: app.restartCommand, description: app.Tr.RestartCommand},
+			},
 		},
 		{
-			key:      gocui.MouseWheelUp,
-			handler:  app.scrollMainUp,
+			name:     "main",
 			viewName: "main",
-			modifier: gocui.ModNone,
+			actions: []action{
+				{name: "copyMode", keys: []string{"<c-y>"}, handler: app.enterCopyMode, description: app.Tr.EnterCopyMode},
+			},
 		},
 		{
-			key:      gocui.KeyTab,
-			handler:  app.switchView,
-			viewName: "main",
-			modifier: gocui.ModNone,
+			name:     "buffer",
+			viewName: "buffer",
+			actions: []action{
+				{name: "send", keys: []string{"<c-l>"}, handler: app.flushBuffer, description: app.Tr.SendBuffer},
+				{name: "prevHistory", keys: []string{"<up>"}, handler: app.prevHistoryItem, description: app.Tr.PrevHistoryItem},
+				{name: "nextHistory", keys: []string{"<down>"}, handler: app.nextHistoryItem, description: app.Tr.NextHistoryItem},
+				{name: "copyMode", keys: []string{"<c-y>"}, handler: app.enterCopyMode, description: app.Tr.EnterCopyMode},
+				{name: "paste", keys: []string{"<c-v>"}, handler: app.pasteYanked, description: app.Tr.PasteYanked},
+				// these go to the program rather than quitting us, so that
+				// they can interrupt it
+				{name: "interrupt", keys: []string{"<c-c>"}, handler: app.sendControlKey(gocui.KeyCtrlC), description: fmt.Sprintf(app.Tr.SendControlKey, "<c-c>")},
+				{name: "suspend", keys: []string{"<c-z>"}, handler: app.sendControlKey(gocui.KeyCtrlZ), description: fmt.Sprintf(app.Tr.SendControlKey, "<c-z>")},
+				{name: "sendQuit", keys: []string{"<c-\\>"}, handler: app.sendControlKey(gocui.KeyCtrlBackslash), description: fmt.Sprintf(app.Tr.SendControlKey, "<c-\\>")},
+				{name: "endOfFile", keys: []string{"<c-d>"}, handler: app.sendControlKey(gocui.KeyCtrlD), description: fmt.Sprintf(app.Tr.SendControlKey, "<c-d>")},
+			},
 		},
 		{
-			key:      gocui.KeyTab,
-			handler:  app.switchView,
-			viewName: "",
-			modifier: gocui.ModNone,
+			name:     "menu",
+			viewName: "menu",
+			actions: []action{
+				{name: "up", keys: []string{"k", "<up>"}, handler: app.menuUp, description: app.Tr.MenuUp},
+				{name: "down", keys: []string{"j", "<down>"}, handler: app.menuDown, description: app.Tr.MenuDown},
+				{name: "confirm", keys: []string{"<enter>", "<space>"}, handler: app.menuConfirm, description: app.Tr.MenuConfirm},
+				{name: "cancel", keys: []string{"q", "<esc>", "<c-c>"}, handler: app.cancelMenu, description: app.Tr.MenuCancel},
+			},
 		},
 		{
-			key:      gocui.KeyCtrlL,
-			handler:  app.flushBuffer,
-			viewName: "buffer",
-			modifier: gocui.ModMotion,
+			name:     "copy",
+			viewName: "copy",
+			actions: []action{
+				{name: "up", keys: []string{"k", "<up>"}, handler: app.copyModeUp, description: app.Tr.CopyModeUp},
+				{name: "down", keys: []string{"j", "<down>"}, handler: app.copyModeDown, description: app.Tr.CopyModeDown},
+				{name: "left", keys: []string{"h", "<left>"}, handler: app.copyModeLeft, description: app.Tr.CopyModeLeft},
+				{name: "right", keys: []string{"l", "<right>"}, handler: app.copyModeRight, description: app.Tr.CopyModeRight},
+				{name: "halfPageUp", keys: []string{"<c-u>", "<pgup>"}, handler: app.copyModeHalfPageUp, description: app.Tr.CopyModeHalfPageUp},
+				{name: "halfPageDown", keys: []string{"<c-d>", "<pgdown>"}, handler: app.copyModeHalfPageDown, description: app.Tr.CopyModeHalfPageDown},
+				{name: "lineStart", keys: []string{"0", "<home>"}, handler: app.copyModeLineStart, description: app.Tr.CopyModeLineStart},
+				{name: "lineEnd", keys: []string{"$", "<end>"}, handler: app.copyModeLineEnd, description: app.Tr.CopyModeLineEnd},
+				{name: "top", keys: []string{"g"}, handler: app.copyModeTop, description: app.Tr.CopyModeTop},
+				{name: "bottom", keys: []string{"G"}, handler: app.copyModeBottom, description: app.Tr.CopyModeBottom},
+				{name: "selectChars", keys: []string{"v", "<space>"}, handler: app.toggleSelection(selectChar), description: app.Tr.SelectChars},
+				{name: "selectLines", keys: []string{"V"}, handler: app.toggleSelection(selectLine), description: app.Tr.SelectLines},
+				{name: "selectBlock", keys: []string{"<c-v>"}, handler: app.toggleSelection(selectBlock), description: app.Tr.SelectBlock},
+				{name: "prevCommand", keys: []string{"[", "{"}, handler: app.copyModePrevBlock, description: app.Tr.PrevCommandBlock},
+				{name: "nextCommand", keys: []string{"]", "}"}, handler: app.copyModeNextBlock, description: app.Tr.NextCommandBlock},
+				{name: "fold", keys: []string{"z", "<tab>"}, handler: app.toggleBlockFold, description: app.Tr.ToggleFold},
+				{name: "yankOutput", keys: []string{"o"}, handler: app.yankBlockOutput, description: app.Tr.YankOutput},
+				{name: "yankCommand", keys: []string{"c"}, handler: app.yankBlockCommand, description: app.Tr.YankCommand},
+				{name: "yank", keys: []string{"y", "<enter>"}, handler: app.copyModeYank, description: app.Tr.YankSelection},
+				{name: "search", keys: []string{"/"}, handler: app.openCopyModeSearch(false), description: app.Tr.SearchForward},
+				// '?' is left to the help, and 'N' searches backward anyway
+				{name: "searchBackward", keys: []string{}, handler: app.openCopyModeSearch(true), description: app.Tr.SearchBackward},
+				{name: "nextMatch", keys: []string{"n"}, handler: func() error { return app.searchNext(false) }, description: app.Tr.NextMatch},
+				{name: "prevMatch", keys: []string{"N"}, handler: func() error { return app.searchNext(true) }, description: app.Tr.PrevMatch},
+				{name: "exit", keys: []string{"q", "<esc>", "<c-c>"}, handler: app.exitCopyMode, description: app.Tr.ExitCopyMode},
+			},
 		},
 		{
-			key:      gocui.KeyArrowUp,
-			handler:  app.prevHistoryItem,
-			viewName: "buffer",
-			modifier: gocui.ModNone,
+			name:     "search",
+			viewName: "search",
+			actions: []action{
+				{name: "confirm", keys: []string{"<enter>"}, handler: app.confirmSearch, description: app.Tr.ConfirmSearch},
+				{name: "cancel", keys: []string{"<esc>", "<c-c>"}, handler: app.closeSearch, description: app.Tr.Cancel},
+			},
 		},
 		{
-			key:      gocui.KeyArrowDown,
-			handler:  app.nextHistoryItem,
-			viewName: "buffer",
-			modifier: gocui.ModNone,
+			name:     "prompt",
+			viewName: "prompt",
+			actions: []action{
+				{name: "confirm", keys: []string{"<enter>"}, handler: app.confirmPrompt, description: app.Tr.Confirm},
+				{name: "cancel", keys: []string{"<esc>", "<c-c>"}, handler: app.closePrompt, description: app.Tr.Cancel},
+			},
 		},
 	}
 
-	quitKeys := []interface{}{gocui.KeyEsc, 'q', gocui.KeyCtrlC}
-	for _, key := range quitKeys {
+	// only while playing back a recording, when the main view has nothing to
+	// send keys to
+	return append(sections, actionSection{
+		name:     "playback",
+		viewName: "main",
+		inactive: app.player == nil,
+		actions: []action{
+			{name: "pause", keys: []string{"<space>", "p"}, handler: app.togglePlaybackPause, description: app.Tr.TogglePlaybackPause},
+			{name: "faster", keys: []string{"+", "="}, handler: app.playbackFaster, description: app.Tr.PlaybackFaster},
+			{name: "slower", keys: []string{"-", "_"}, handler: app.playbackSlower, description: app.Tr.PlaybackSlower},
+			{name: "seekBackward", keys: []string{"<left>", "h"}, handler: app.seekBackward, description: app.Tr.SeekBackward},
+			{name: "seekForward", keys: []string{"<right>", "l"}, handler: app.seekForward, description: app.Tr.SeekForward},
+		},
+	})
+}
+
+// actionBindings binds every action to its keys: those in the keybindings
+// config if it mentions the action, otherwise the defaults. Problems with the
+// config are all reported together.
+func (app *App) actionBindings(config map[string]map[string]config.KeyList) ([]binding, []string) {
+	sections := app.actionSections()
+	problems := []string{}
+
+	known := map[string]map[string]bool{}
+	for _, section := range sections {
+		known[section.name] = map[string]bool{}
+		for _, action := range section.actions {
+			known[section.name][action.name] = true
+		}
+	}
+	for _, sectionName := range sortedKeys(config) {
+		if _, ok := known[sectionName]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown section '%s'", sectionName))
+			continue
+		}
+		for actionName := range config[sectionName] {
+			if !known[sectionName][actionName] {
+				problems = append(problems, fmt.Sprintf("unknown action '%s.%s'", sectionName, actionName))
+			}
+		}
+	}
+
+	bindings := []binding{}
+	for _, section := range sections {
+		for _, action := range section.actions {
+			keys := action.keys
+			if configured, ok := config[section.name][action.name]; ok {
+				keys = configured
+			}
+
+			for _, name := range keys {
+				key, mod, err := parseKey(name)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s.%s: %v", section.name, action.name, err))
+					continue
+				}
+				_, isChar := key.(rune)
+				if isChar && editableViews[section.viewName] {
+					problems = append(problems, fmt.Sprintf("%s.%s: '%s' would be typed rather than bound", section.name, action.name, name))
+					continue
+				}
+				if section.inactive {
+					continue
+				}
+
+				viewNames := []string{section.viewName}
+				if action.inProgram && !(isChar && mod == gocui.ModNone) {
+					viewNames = append(viewNames, "main")
+				}
+				for _, viewName := range viewNames {
+					bindings = append(bindings, binding{
+						key:         key,
+						modifier:    mod,
+						viewName:    viewName,
+						handler:     action.handler,
+						description: action.description,
+						section:     section.name,
+						action:      action.name,
+					})
+				}
+				if action.inEmptyBuffer && isChar && mod == gocui.ModNone {
+					bindings = append(bindings, binding{
+						key:         key,
+						viewName:    "buffer",
+						handler:     app.unlessTyping(key.(rune), action.handler),
+						description: action.description,
+						section:     section.name,
+						action:      action.name,
+					})
+				}
+			}
+		}
+	}
+	return bindings, problems
+}
+
+// conflicts reports keys that are bound to more than one thing in the same
+// view, since only one of them would ever happen
+func conflicts(bindings []binding) []string {
+	problems := []string{}
+	seen := map[string]binding{}
+	for _, b := range bindings {
+		id := b.viewName + " " + keyName(b.key, b.modifier)
+		other, ok := seen[id]
+		if !ok {
+			seen[id] = b
+			continue
+		}
+		if other.section == b.section && other.action == b.action && other.action != "" {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf(
+			"'%s' is bound to both %s and %s",
+			keyName(b.key, b.modifier),
+			other.name(),
+			b.name(),
+		))
+	}
+	return problems
+}
+
+// name is how we refer to the binding in errors about the config
+func (b binding) name() string {
+	if b.action == "" {
+		return fmt.Sprintf("'%s' in %s", b.description, b.section)
+	}
+	return b.section + "." + b.action
+}
+
+func sortedKeys(m map[string]map[string]config.KeyList) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// makeKeybindings makes every binding, with the keys from the keybindings
+// config
+func (app *App) makeKeybindings(config map[string]map[string]config.KeyList) ([]binding, error) {
+	bindings, problems := app.actionBindings(config)
+
+	bindings = append(bindings,
+		binding{
+			key:         gocui.MouseWheelDown,
+			handler:     app.scrollMainDown,
+			viewName:    "main",
+			description: app.Tr.ScrollDown,
+			section:     "main",
+		},
+		binding{
+			key:         gocui.MouseWheelUp,
+			handler:     app.scrollMainUp,
+			viewName:    "main",
+			description: app.Tr.ScrollUp,
+			section:     "main",
+		},
+		// clicking the pane that isn't focused focuses it
+		binding{
+			key:         gocui.MouseLeft,
+			handler:     app.focusOtherPane,
+			viewName:    "pane",
+			description: app.Tr.FocusOtherPane,
+			section:     "main",
+		},
+	)
+	for _, item := range app.prefixMenuItems() {
 		bindings = append(bindings, binding{
-			key:      key,
-			handler:  app.quit,
-			viewName: "",
+			key:         item.key,
+			handler:     app.menuShortcut(item.key),
+			viewName:    "menu",
+			description: fmt.Sprintf(app.Tr.PrefixMenuShortcut, item.label),
+			section:     "menu",
 		})
 	}
 
+	problems = append(problems, conflicts(bindings)...)
+	if len(problems) > 0 {
+		return nil, errors.New("keybindings config:\n  " + strings.Join(problems, "\n  "))
+	}
+	return bindings, nil
+}
+
+// setKeybindings replaces whatever bindings we've set with these
+func (app *App) setKeybindings(bindings []binding) error {
+	deleted := map[string]bool{}
+	for _, b := range app.bindings {
+		if !deleted[b.viewName] {
+			app.g.DeleteKeybindings(b.viewName)
+			deleted[b.viewName] = true
+		}
+	}
+
 	for _, binding := range bindings {
-		if err := app.g.SetBlindKeybinding(binding.viewName, nil, binding.key, gocui.ModNone, binding.handler); err != nil {
+		if err := app.g.SetBlindKeybinding(binding.viewName, nil, binding.key, binding.modifier, binding.handler); err != nil {
 			return err
 		}
 	}
+	app.bindings = bindings
 
 	return nil
 }
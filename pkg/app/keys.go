@@ -0,0 +1,117 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jesseduffield/gocui"
+)
+
+// keyNames are the keys that the config can name, besides single characters
+// and control keys like '<c-q>'
+var keyNames = map[string]gocui.Key{
+	"<esc>":       gocui.KeyEsc,
+	"<enter>":     gocui.KeyEnter,
+	"<tab>":       gocui.KeyTab,
+	"<space>":     gocui.KeySpace,
+	"<backspace>": gocui.KeyBackspace2,
+	"<delete>":    gocui.KeyDelete,
+	"<insert>":    gocui.KeyInsert,
+	"<home>":      gocui.KeyHome,
+	"<end>":       gocui.KeyEnd,
+	"<pgup>":      gocui.KeyPgup,
+	"<pgdown>":    gocui.KeyPgdn,
+	"<up>":        gocui.KeyArrowUp,
+	"<down>":      gocui.KeyArrowDown,
+	"<left>":      gocui.KeyArrowLeft,
+	"<right>":     gocui.KeyArrowRight,
+	"<f1>":        gocui.KeyF1,
+	"<f2>":        gocui.KeyF2,
+	"<f3>":        gocui.KeyF3,
+	"<f4>":        gocui.KeyF4,
+	"<f5>":        gocui.KeyF5,
+	"<f6>":        gocui.KeyF6,
+	"<f7>":        gocui.KeyF7,
+	"<f8>":        gocui.KeyF8,
+	"<f9>":        gocui.KeyF9,
+	"<f10>":       gocui.KeyF10,
+	"<f11>":       gocui.KeyF11,
+	"<f12>":       gocui.KeyF12,
+	"<c-\\>":      gocui.KeyCtrlBackslash,
+}
+
+// parseKey turns a key from the config into something gocui can bind: a
+// single character like 'q', a control key from '<c-a>' to '<c-z>', one of
+// keyNames, or any of those with alt held, like '<a-x>' or '<a-enter>'
+func parseKey(name string) (interface{}, gocui.Modifier, error) {
+	lower := strings.ToLower(name)
+	if len(name) > 4 && strings.HasPrefix(lower, "<a-") && strings.HasSuffix(lower, ">") {
+		inner := name[3 : len(name)-1]
+		if len([]rune(inner)) > 1 {
+			inner = "<" + inner + ">"
+		}
+		key, mod, err := parseKey(inner)
+		if err != nil || mod != gocui.ModNone {
+			return nil, 0, fmt.Errorf("unknown key '%s'", name)
+		}
+		return key, gocui.ModAlt, nil
+	}
+	if key, ok := keyNames[lower]; ok {
+		return key, gocui.ModNone, nil
+	}
+	if len(lower) == 5 && strings.HasPrefix(lower, "<c-") && strings.HasSuffix(lower, ">") && lower[3] >= 'a' && lower[3] <= 'z' {
+		return gocui.KeyCtrlA + gocui.Key(lower[3]-'a'), gocui.ModNone, nil
+	}
+
+	runes := []rune(name)
+	if len(runes) == 1 {
+		if runes[0] == ' ' {
+			return gocui.KeySpace, gocui.ModNone, nil
+		}
+		return runes[0], gocui.ModNone, nil
+	}
+	return nil, 0, fmt.Errorf("unknown key '%s'", name)
+}
+
+// mouseKeyNames are for showing mouse bindings in the help. They can't be
+// bound from the config.
+var mouseKeyNames = map[gocui.Key]string{
+	gocui.MouseLeft:      "<click>",
+	gocui.MouseRight:     "<right-click>",
+	gocui.MouseMiddle:    "<middle-click>",
+	gocui.MouseWheelUp:   "<wheel-up>",
+	gocui.MouseWheelDown: "<wheel-down>",
+}
+
+// keyName is how we show a key in the help, which is how the config would
+// name it
+func keyName(key interface{}, mod gocui.Modifier) string {
+	if mod == gocui.ModAlt {
+		name := keyName(key, gocui.ModNone)
+		if len(name) > 1 {
+			name = name[1 : len(name)-1]
+		}
+		return "<a-" + name + ">"
+	}
+
+	switch k := key.(type) {
+	case rune:
+		if k == ' ' {
+			return "<space>"
+		}
+		return string(k)
+	case gocui.Key:
+		for name, named := range keyNames {
+			if named == k {
+				return name
+			}
+		}
+		if name, ok := mouseKeyNames[k]; ok {
+			return name
+		}
+		if k >= gocui.KeyCtrlA && k <= gocui.KeyCtrlZ {
+			return fmt.Sprintf("<c-%c>", 'a'+rune(k-gocui.KeyCtrlA))
+		}
+	}
+	return fmt.Sprint(key)
+}
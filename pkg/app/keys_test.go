@@ -0,0 +1,122 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/i18n"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKey(t *testing.T) {
+	type scenario struct {
+		name        string
+		key         interface{}
+		modifier    gocui.Modifier
+		expectError bool
+	}
+
+	scenarios := []scenario{
+		{"q", 'q', gocui.ModNone, false},
+		{" ", gocui.KeySpace, gocui.ModNone, false},
+		{"<space>", gocui.KeySpace, gocui.ModNone, false},
+		{"<c-q>", gocui.KeyCtrlQ, gocui.ModNone, false},
+		{"<F1>", gocui.KeyF1, gocui.ModNone, false},
+		{"<a-x>", 'x', gocui.ModAlt, false},
+		{"<a-enter>", gocui.KeyEnter, gocui.ModAlt, false},
+		{"", nil, 0, true},
+		{"qq", nil, 0, true},
+		{"<ctrl-q>", nil, 0, true},
+		{"<c-1>", nil, 0, true},
+		{"<f13>", nil, 0, true},
+		{"<a->", nil, 0, true},
+		{"<a-<c-q>>", nil, 0, true},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			key, modifier, err := parseKey(s.name)
+			if s.expectError {
+				assert.EqualError(t, err, "unknown key '"+s.name+"'")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, s.key, key)
+			assert.Equal(t, s.modifier, modifier)
+		})
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	type scenario struct {
+		name     string
+		bindings []binding
+		expected []string
+	}
+
+	scenarios := []scenario{
+		{
+			"one key for two actions in the same view",
+			[]binding{
+				{key: 'y', viewName: "copy", section: "copy", action: "copy"},
+				{key: 'y', viewName: "copy", section: "copy", action: "exit"},
+			},
+			[]string{"'y' is bound to both copy.copy and copy.exit"},
+		},
+		{
+			"one key for an action and a fixed binding",
+			[]binding{
+				{key: 'a', viewName: "menu", section: "menu", action: "select"},
+				{key: 'a', viewName: "menu", section: "menu", description: "new tab"},
+			},
+			[]string{"'a' is bound to both menu.select and 'new tab' in menu"},
+		},
+		{
+			"an action given the same key twice",
+			[]binding{
+				{key: gocui.KeyF1, viewName: "", section: "global", action: "help"},
+				{key: gocui.KeyF1, viewName: "", section: "global", action: "help"},
+			},
+			[]string{},
+		},
+		{
+			"a global binding shadowed by one in a view",
+			[]binding{
+				{key: 'q', viewName: "", section: "global", action: "quitIfExited"},
+				{key: 'q', viewName: "menu", section: "menu", action: "close"},
+			},
+			[]string{},
+		},
+		{
+			"the same key with and without alt",
+			[]binding{
+				{key: 'x', viewName: "buffer", section: "buffer", action: "send"},
+				{key: 'x', modifier: gocui.ModAlt, viewName: "buffer", section: "buffer", action: "copyMode"},
+			},
+			[]string{},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			assert.Equal(t, s.expected, conflicts(s.bindings))
+		})
+	}
+}
+
+func TestKeyHint(t *testing.T) {
+	app := &App{Tr: i18n.NewTranslationSet(logrus.NewEntry(logrus.New())), bindings: []binding{
+		{key: gocui.KeyF7, viewName: "", section: "global", action: "prevTab"},
+		{key: gocui.KeyF7, viewName: "main", section: "global", action: "prevTab"},
+		{key: 'p', modifier: gocui.ModAlt, viewName: "", section: "global", action: "prevTab"},
+		{key: gocui.KeyF8, viewName: "", section: "global", action: "nextTab"},
+		{key: gocui.KeyCtrlY, viewName: "main", section: "main", action: "copyMode"},
+		{key: ' ', viewName: "main", section: "playback", action: "pause"},
+	}}
+
+	assert.Equal(t, "<f7>/<f8>", app.keyHint("global", "prevTab", "nextTab"))
+	assert.Equal(t, "<f8>", app.keyHint("global", "renameTab", "nextTab"))
+	assert.Equal(t, "(unbound)", app.keyHint("global", "renameTab"))
+	assert.Equal(t, "<f7>, <c-y>", app.programKeys())
+}
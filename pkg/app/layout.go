@@ -1,26 +1,35 @@
 package app
 
 import (
-	"fmt"
-	"time"
-
 	"github.com/jesseduffield/gocui"
-	"github.com/jesseduffield/pty"
 )
 
+// onResize keeps every session's terminal the size of its pane, whichever tab
+// is showing. The pane of a split tab that's hidden behind a full screen one
+// stays the size it was.
 func (app *App) onResize() error {
-	if app.ptmx == nil {
-		return nil
+	for _, t := range app.tabs {
+		for i, s := range t.panes {
+			if t == app.tab && app.fullScreen && i != t.focused {
+				continue
+			}
+			width, height := app.paneRect(t, i).size()
+			if err := app.resizeSession(s, width, height); err != nil {
+				return err
+			}
+		}
 	}
-	width, height := app.views.main.Size()
-	return pty.Setsize(app.ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
+	return nil
 }
 
 func (app *App) layout(g *gocui.Gui) error {
 	width, height := g.Size()
 
 	bufferHeight := 3
-	if app.views.buffer != nil {
+	if app.player != nil {
+		// there's nothing to type into when we're replaying a recording
+		bufferHeight = 0
+	} else if app.views.buffer != nil {
 		linesHeight := app.views.buffer.LinesHeight()
 		if linesHeight == 0 {
 			linesHeight = 1
@@ -30,38 +39,96 @@ func (app *App) layout(g *gocui.Gui) error {
 
 	infoHeight := 1
 
-	if v, err := g.SetView("main", -1, -1, width, height-bufferHeight-infoHeight, 0); err != nil {
+	bufferY0, bufferY1 := height-bufferHeight-infoHeight, height-2
+	infoY0, infoY1 := height-2, height
+	if app.fullScreen {
+		bufferHeight, infoHeight = 0, 0
+		// views whose bottom edge is above their top edge don't get drawn
+		bufferY0, bufferY1 = height, height-1
+		infoY0, infoY1 = height, height-1
+	}
+
+	mainX0, mainY0 := -1, -1
+	if app.gutterShown() {
+		mainX0 = gutterWidth - 1
+	}
+	if app.tabsShown() {
+		mainY0 = 0
+	}
+	if err := app.layoutTabs(g); err != nil {
+		return err
+	}
+	if err := app.layoutGutter(g, mainY0, height-bufferHeight-infoHeight); err != nil {
+		return err
+	}
+
+	app.paneArea = rect{mainX0, mainY0, width, height - bufferHeight - infoHeight}
+	mainRect := app.paneRect(app.tab, app.tab.focused)
+	if v, err := g.SetView("main", mainRect.x0, mainRect.y0, mainRect.x1, mainRect.y1, 0); err != nil {
 		if err.Error() != "unknown view" {
 			return err
 		}
 		v.Frame = false
-		v.Wrap = true
-		// autoscroll is best turned off when you're in a full-window application like vim or lazygit. It would be good to make this adjustable while in the app.
-		// TODO: take escape codes like [?1049 to say we're turning off wrap and autoscroll.
-		v.Autoscroll = true
+		// the screen does its own wrapping and scrolling
+		v.Wrap = false
+		v.Autoscroll = false
 		app.views.main = v
+		if app.session.screen == nil {
+			width, height := v.Size()
+			app.session.screen = app.newScreen(app.session, width, height)
+		}
 
 		app.g.SetCurrentView("main")
 	}
 
-	if v, err := g.SetView("buffer", 0, height-bufferHeight-infoHeight, width-1, height-2, 0); err != nil {
-		if err.Error() != "unknown view" {
-			return err
+	if err := app.layoutPanes(g); err != nil {
+		return err
+	}
+
+	if app.player == nil {
+		if v, err := g.SetView("buffer", 0, bufferY0, width-1, bufferY1, 0); err != nil {
+			if err.Error() != "unknown view" {
+				return err
+			}
+			v.Frame = true
+			v.Wrap = true
+			v.Autoscroll = true
+			v.Editable = true
+			app.views.buffer = v
 		}
-		v.Frame = true
-		v.Wrap = true
-		v.Autoscroll = true
-		v.Editable = true
-		app.views.buffer = v
 	}
 
-	if v, err := g.SetView("info", -1, height-2, width-1, height, 0); err != nil {
+	if v, err := g.SetView("info", -1, infoY0, width-1, infoY1, 0); err != nil {
 		if err.Error() != "unknown view" {
 			return err
 		}
 		v.Frame = false
 		app.views.info = v
-		fmt.Fprint(v, "use tab to switch from the program to the buffer")
+		app.renderDefaultInfo()
+	}
+
+	if err := app.layoutProcessStats(g, infoY0, infoY1); err != nil {
+		return err
+	}
+
+	if err := app.renderMain(); err != nil {
+		return err
+	}
+
+	if err := app.layoutCopyView(g); err != nil {
+		return err
+	}
+
+	if err := app.layoutSearchView(g); err != nil {
+		return err
+	}
+
+	if err := app.layoutMenu(g); err != nil {
+		return err
+	}
+
+	if err := app.layoutPrompt(g); err != nil {
+		return err
 	}
 
 	if !app.started {
@@ -69,35 +136,57 @@ func (app *App) layout(g *gocui.Gui) error {
 		go app.onFirstRender()
 	}
 
-	mainViewWidth, mainViewHeight := app.views.main.Size()
-	if mainViewWidth != app.prevWidth || mainViewHeight != app.prevHeight {
-		app.prevWidth = mainViewWidth
-		app.prevHeight = mainViewHeight
-		if err := app.onResize(); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return app.onResize()
 }
 
 func (app *App) onFirstRender() {
-	go func() {
-		if err := app.runCommandInPty(app.views.main); err != nil {
-			app.g.Update(func(*gocui.Gui) error {
-				return err
-			})
-		}
+	if app.player != nil {
+		go app.runPlayer()
+	} else if app.pickingProfile {
+		app.update(app.openProfilePicker)
+	} else {
+		app.startCommand(app.session)
+	}
+
+	app.renderLoop.run(app.g)
+}
 
-		// doing this so that if the buffer is focused we can press 'q' to exit
-		app.views.buffer.Editable = false
-	}()
+// startCommand runs the session's command in the background
+func (app *App) startCommand(s *session) {
+	s.done = make(chan struct{})
+	go app.runCommand(s)
+}
 
-	// TODO, get gocui to receive a callback on taint so that we don't need to poll
-	ticker := time.NewTicker(time.Millisecond * 30)
-	for range ticker.C {
+func (app *App) runCommand(s *session) {
+	status, err := app.runCommandInPty(s)
+	close(s.done)
+	if err != nil {
+		// the command couldn't start, which is only this session's problem;
+		// the others carry on
 		app.g.Update(func(*gocui.Gui) error {
-			return nil
+			s.exited = true
+			s.exitStatus = exitStatus{code: -1}
+			if s.closed || s != app.session {
+				return nil
+			}
+			if showErr := app.showSession(); showErr != nil {
+				return showErr
+			}
+			return app.renderError(err)
 		})
+		return
 	}
+
+	app.g.Update(func(*gocui.Gui) error {
+		s.exited = true
+		s.exitStatus = status
+		if s.closed {
+			return nil
+		}
+		app.scheduleRestart(s)
+		if s != app.session {
+			return nil
+		}
+		return app.showSession()
+	})
 }
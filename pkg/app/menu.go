@@ -0,0 +1,216 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/jesseduffield/gocui"
+)
+
+type menuItem struct {
+	// key picks the item without moving to it, if it's set
+	key     rune
+	label   string
+	onPress func() error
+}
+
+// text is what the menu shows for the item
+func (item *menuItem) text() string {
+	if item.key == 0 {
+		return item.label
+	}
+	return fmt.Sprintf("%c  %s", item.key, item.label)
+}
+
+// menuState is for a popup listing some options for the user to pick from
+type menuState struct {
+	title    string
+	items    []*menuItem
+	selected int
+	prevView string
+	// onCancel is called if the menu's closed without picking anything
+	onCancel func() error
+	// refresh, if set, updates the items every so often while the menu's open
+	refresh func() error
+}
+
+func (app *App) createMenu(title string, items []*menuItem) error {
+	prevView := "main"
+	if currentView := app.g.CurrentView(); currentView != nil && currentView.Name() != "menu" {
+		prevView = currentView.Name()
+	} else if app.menu != nil {
+		prevView = app.menu.prevView
+	}
+
+	app.menu = &menuState{
+		title:    title,
+		items:    items,
+		prevView: prevView,
+	}
+
+	if err := app.layoutMenu(app.g); err != nil {
+		return err
+	}
+	if _, err := app.g.SetCurrentView("menu"); err != nil {
+		return err
+	}
+
+	return app.renderMenu()
+}
+
+func (app *App) closeMenu() error {
+	if app.menu == nil {
+		return nil
+	}
+
+	prevView := app.menu.prevView
+	app.menu = nil
+	app.views.menu = nil
+
+	if err := app.g.DeleteView("menu"); err != nil {
+		return err
+	}
+	_, err := app.g.SetCurrentView(prevView)
+	return err
+}
+
+// cancelMenu closes the menu without picking anything
+func (app *App) cancelMenu() error {
+	if app.menu == nil {
+		return nil
+	}
+
+	onCancel := app.menu.onCancel
+	if err := app.closeMenu(); err != nil {
+		return err
+	}
+	if onCancel == nil {
+		return nil
+	}
+	return onCancel()
+}
+
+// layoutMenu centres the menu over the main view
+func (app *App) layoutMenu(g *gocui.Gui) error {
+	if app.menu == nil {
+		return nil
+	}
+
+	width, height := g.Size()
+
+	menuWidth := len(app.menu.title) + 4
+	for _, item := range app.menu.items {
+		if len(item.text())+2 > menuWidth {
+			menuWidth = len(item.text()) + 2
+		}
+	}
+	if menuWidth > width-2 {
+		menuWidth = width - 2
+	}
+	menuHeight := len(app.menu.items)
+	if menuHeight > height-4 {
+		menuHeight = height - 4
+	}
+
+	x0 := (width - menuWidth) / 2
+	y0 := (height - menuHeight) / 2
+	v, err := g.SetView("menu", x0, y0-1, x0+menuWidth+1, y0+menuHeight, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = true
+		app.views.menu = v
+	}
+	v.Title = app.menu.title
+
+	_, err = g.SetViewOnTop("menu")
+	return err
+}
+
+// refreshMenu brings the menu's items up to date if it keeps changing
+func (app *App) refreshMenu() error {
+	if app.menu == nil || app.menu.refresh == nil {
+		return nil
+	}
+	if err := app.menu.refresh(); err != nil {
+		return err
+	}
+	if err := app.layoutMenu(app.g); err != nil {
+		return err
+	}
+	return app.renderMenu()
+}
+
+func (app *App) renderMenu() error {
+	v := app.views.menu
+	if app.menu == nil || v == nil {
+		return nil
+	}
+
+	_, height := v.Size()
+	top := 0
+	if app.menu.selected >= height {
+		top = app.menu.selected - height + 1
+	}
+
+	v.Clear()
+	for row := 0; row < height && top+row < len(app.menu.items); row++ {
+		label := app.menu.items[top+row].text()
+		if top+row == app.menu.selected {
+			label = "\x1b[7m" + label + "\x1b[0m"
+		}
+		fmt.Fprintf(v, "\x1b[%d;1H%s", row+1, label)
+	}
+	v.Autoscroll = false
+
+	return v.SetCursor(0, app.menu.selected-top)
+}
+
+func (app *App) menuUp() error {
+	if app.menu == nil || app.menu.selected == 0 {
+		return nil
+	}
+	app.menu.selected--
+	return app.renderMenu()
+}
+
+func (app *App) menuDown() error {
+	if app.menu == nil || app.menu.selected >= len(app.menu.items)-1 {
+		return nil
+	}
+	app.menu.selected++
+	return app.renderMenu()
+}
+
+func (app *App) menuConfirm() error {
+	if app.menu == nil || len(app.menu.items) == 0 {
+		return app.closeMenu()
+	}
+
+	return app.pressMenuItem(app.menu.items[app.menu.selected])
+}
+
+// menuShortcut presses whichever item in the open menu has the given key
+func (app *App) menuShortcut(key rune) func() error {
+	return func() error {
+		if app.menu == nil {
+			return nil
+		}
+		for _, item := range app.menu.items {
+			if item.key == key {
+				return app.pressMenuItem(item)
+			}
+		}
+		return nil
+	}
+}
+
+func (app *App) pressMenuItem(item *menuItem) error {
+	if err := app.closeMenu(); err != nil {
+		return err
+	}
+	if item.onPress == nil {
+		return nil
+	}
+	return item.onPress()
+}
@@ -0,0 +1,240 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/procs"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// processStatsInterval is how often we look at what the command is up to
+const processStatsInterval = time.Second
+
+// signals we offer to send from the process tree
+var treeSignals = []syscall.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGKILL,
+	syscall.SIGSTOP,
+	syscall.SIGCONT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGSTOP: "SIGSTOP",
+	syscall.SIGCONT: "SIGCONT",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+}
+
+// cpuSampler works out how busy processes have been between looks at them
+type cpuSampler struct {
+	last map[int]cpuSample
+}
+
+type cpuSample struct {
+	cpuTime time.Duration
+	at      time.Time
+}
+
+// sample returns how much of a CPU each process has used since the last
+// sample, as a percentage. Processes we haven't seen before don't get one.
+func (c *cpuSampler) sample(processes []procs.Process, now time.Time) map[int]float64 {
+	percents := map[int]float64{}
+	next := make(map[int]cpuSample, len(processes))
+	for _, p := range processes {
+		if last, ok := c.last[p.Pid]; ok && now.After(last.at) {
+			percents[p.Pid] = float64(p.CPUTime-last.cpuTime) / float64(now.Sub(last.at)) * 100
+		}
+		next[p.Pid] = cpuSample{cpuTime: p.CPUTime, at: now}
+	}
+	c.last = next
+	return percents
+}
+
+// monitorProcesses keeps the stats for the focused session's command up to
+// date, and the process tree while it's open
+func (app *App) monitorProcesses() {
+	if !procs.Supported() {
+		return
+	}
+
+	ticker := time.NewTicker(processStatsInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		app.g.Update(func(*gocui.Gui) error {
+			return app.updateProcessStats()
+		})
+	}
+}
+
+// commandPid returns the pid of the focused session's command, if it's
+// running
+func (app *App) commandPid() (int, bool) {
+	s := app.session
+	if s.stdin == nil || s.exited || s.cmd.Process == nil {
+		return 0, false
+	}
+	return s.cmd.Process.Pid, true
+}
+
+func (app *App) updateProcessStats() error {
+	app.processStats = ""
+	pid, ok := app.commandPid()
+	if !ok {
+		app.processTree = nil
+		return app.refreshMenu()
+	}
+
+	// the process tree needs all of the command's descendants looking at,
+	// which we don't bother with while it's closed
+	var processes []procs.Process
+	if app.menu != nil && app.menu.refresh != nil {
+		all, err := procs.List()
+		if err != nil {
+			return err
+		}
+		app.processTree = procs.Tree(pid, all)
+		for _, entry := range app.processTree {
+			processes = append(processes, entry.Process)
+		}
+	} else if p, err := procs.Read(pid); err == nil {
+		processes = []procs.Process{p}
+	}
+	app.cpuPercents = app.cpuSampler.sample(processes, time.Now())
+
+	for _, p := range processes {
+		if p.Pid == pid {
+			app.processStats = fmt.Sprintf(
+				app.Tr.ProcessStats,
+				pid,
+				time.Since(app.session.commandStarted).Round(time.Second),
+				app.formatCPU(pid),
+				procs.FormatMemory(p.RSS),
+			)
+		}
+	}
+	return app.refreshMenu()
+}
+
+func (app *App) formatCPU(pid int) string {
+	percent, ok := app.cpuPercents[pid]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", percent)
+}
+
+// layoutProcessStats puts the stats for the command at the right of the info
+// view
+func (app *App) layoutProcessStats(g *gocui.Gui, y0 int, y1 int) error {
+	if app.processStats == "" || app.fullScreen {
+		if err := g.DeleteView("stats"); err != nil && err.Error() != "unknown view" {
+			return err
+		}
+		return nil
+	}
+
+	width, _ := g.Size()
+	v, err := g.SetView("stats", width-len(app.processStats)-2, y0, width, y1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = false
+		v.Wrap = false
+	}
+	v.Clear()
+	fmt.Fprint(v, utils.ColoredString(app.processStats, color.FgCyan))
+	return nil
+}
+
+// openProcessTree shows the command and its descendants, with how busy each
+// is and how much memory it's using. Picking one offers signals to send it.
+func (app *App) openProcessTree() error {
+	pid, ok := app.commandPid()
+	if !ok {
+		return app.renderError(errors.New(app.Tr.NotRunning))
+	}
+	all, err := procs.List()
+	if err != nil {
+		return app.renderError(err)
+	}
+	app.processTree = procs.Tree(pid, all)
+
+	if err := app.createMenu(app.Tr.ProcessTreeTitle, app.processTreeItems()); err != nil {
+		return err
+	}
+	app.menu.refresh = func() error {
+		selectedPid := -1
+		if app.menu.selected < len(app.processTree) {
+			selectedPid = app.processTree[app.menu.selected].Pid
+		}
+		app.menu.items = app.processTreeItems()
+		// the selection follows its process as others come and go
+		app.menu.selected = 0
+		for i, entry := range app.processTree {
+			if entry.Pid == selectedPid {
+				app.menu.selected = i
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+func (app *App) processTreeItems() []*menuItem {
+	items := make([]*menuItem, len(app.processTree))
+	for i, entry := range app.processTree {
+		entry := entry
+		items[i] = &menuItem{
+			label: fmt.Sprintf(
+				"%7d %s %5s %7s  %s%s",
+				entry.Pid,
+				entry.State,
+				app.formatCPU(entry.Pid),
+				procs.FormatMemory(entry.RSS),
+				strings.Repeat("  ", entry.Depth),
+				entry.Command,
+			),
+			onPress: func() error { return app.openSignalMenu(entry.Process) },
+		}
+	}
+	return items
+}
+
+func (app *App) openSignalMenu(p procs.Process) error {
+	items := make([]*menuItem, len(treeSignals))
+	for i, signal := range treeSignals {
+		signal := signal
+		items[i] = &menuItem{
+			label:   fmt.Sprintf("%-8s %s", signalNames[signal], signal),
+			onPress: func() error { return app.sendSignal(p, signal) },
+		}
+	}
+	return app.createMenu(fmt.Sprintf(app.Tr.SendSignalTitle, p.Pid), items)
+}
+
+// sendSignal sends the signal and goes back to the process tree to see what
+// it did
+func (app *App) sendSignal(p procs.Process, signal syscall.Signal) error {
+	if err := syscall.Kill(p.Pid, signal); err != nil {
+		return app.renderError(err)
+	}
+	app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.SentSignal, signalNames[signal], p.Pid), color.FgGreen))
+	return app.openProcessTree()
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+)
+
+// mouse buttons as xterm numbers them in mouse reports
+const (
+	mouseRelease   = 3
+	mouseWheelUp   = 64
+	mouseWheelDown = 65
+)
+
+// the X10 and UTF-8 encodings offset everything by 32 to keep it printable
+const mouseOffset = 32
+
+// encodeMouseEvent encodes a button press, or its release, the way the program
+// asked for. x and y are zero-based cells within the main view. Only SGR says
+// which button was released. It returns nil if the event can't be
+// represented, e.g. when the coordinates are too big for the X10 encoding.
+func encodeMouseEvent(encoding terminal.MouseEncoding, button int, release bool, x int, y int) []byte {
+	if encoding == terminal.MouseEncodingSGR {
+		final := 'M'
+		if release {
+			final = 'm'
+		}
+		return []byte(fmt.Sprintf("\x1b[<%d;%d;%d%c", button, x+1, y+1, final))
+	}
+	if release {
+		button = mouseRelease
+	}
+
+	switch encoding {
+	case terminal.MouseEncodingURXVT:
+		return []byte(fmt.Sprintf("\x1b[%d;%d;%dM", button+mouseOffset, x+1, y+1))
+	case terminal.MouseEncodingUTF8:
+		result := []byte("\x1b[M")
+		for _, value := range []int{button, x + 1, y + 1} {
+			value += mouseOffset
+			if value > 2047 {
+				return nil
+			}
+			result = append(result, []byte(string(rune(value)))...)
+		}
+		return result
+	default:
+		result := []byte("\x1b[M")
+		for _, value := range []int{button, x + 1, y + 1} {
+			value += mouseOffset
+			if value > 255 {
+				return nil
+			}
+			result = append(result, byte(value))
+		}
+		return result
+	}
+}
+
+// forwardMouse sends a mouse event to the program if it has asked for them,
+// returning false if it hasn't
+func (app *App) forwardMouse(button int) (bool, error) {
+	mode, encoding := app.session.screen.Mouse()
+	if mode == terminal.MouseNone || app.views.main.StdinWriter == nil {
+		return false, nil
+	}
+	if mode == terminal.MouseX10 && button >= mouseWheelUp {
+		// X10 mode only reports button presses
+		return true, nil
+	}
+
+	// gocui doesn't tell keybinding handlers where the pointer is, so we
+	// report the event at the program's cursor, which is always within the
+	// main view. That's enough for scrolling but not for clicking.
+	x, y := app.session.screen.Cursor()
+	event := encodeMouseEvent(encoding, button, false, x, y)
+	if event == nil {
+		return true, nil
+	}
+
+	_, err := app.views.main.StdinWriter.Write(event)
+	return true, err
+}
+
+// reportFocus tells the program that the main view has gained or lost focus,
+// if it has asked to know
+func (app *App) reportFocus(focused bool) error {
+	if app.session.screen == nil || !app.session.screen.FocusReporting() || app.views.main.StdinWriter == nil {
+		return nil
+	}
+
+	event := "\x1b[O"
+	if focused {
+		event = "\x1b[I"
+	}
+	_, err := app.views.main.StdinWriter.Write([]byte(event))
+	return err
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeMouseEvent(t *testing.T) {
+	type scenario struct {
+		name     string
+		encoding terminal.MouseEncoding
+		button   int
+		release  bool
+		x, y     int
+		expected []byte
+	}
+
+	scenarios := []scenario{
+		{"X10 press", terminal.MouseEncodingDefault, 0, false, 0, 0, []byte("\x1b[M !!")},
+		{"X10 wheel", terminal.MouseEncodingDefault, mouseWheelUp, false, 9, 4, []byte("\x1b[M`*%")},
+		{"X10 release", terminal.MouseEncodingDefault, 2, true, 1, 1, []byte("\x1b[M#\"\"")},
+		{"X10 at the last column it can say", terminal.MouseEncodingDefault, 0, false, 222, 0, []byte("\x1b[M \xff!")},
+		{"X10 past the last column it can say", terminal.MouseEncodingDefault, 0, false, 223, 0, nil},
+		{"UTF-8 past where X10 stops", terminal.MouseEncodingUTF8, 0, false, 300, 0, []byte("\x1b[M ō!")},
+		{"UTF-8 release", terminal.MouseEncodingUTF8, 1, true, 0, 0, []byte("\x1b[M#!!")},
+		{"UTF-8 past the last column it can say", terminal.MouseEncodingUTF8, 0, false, 2015, 0, nil},
+		{"SGR press", terminal.MouseEncodingSGR, 2, false, 4, 9, []byte("\x1b[<2;5;10M")},
+		{"SGR release", terminal.MouseEncodingSGR, 2, true, 4, 9, []byte("\x1b[<2;5;10m")},
+		{"SGR wheel far out", terminal.MouseEncodingSGR, mouseWheelDown, false, 999, 0, []byte("\x1b[<65;1000;1M")},
+		{"URXVT press", terminal.MouseEncodingURXVT, 0, false, 0, 0, []byte("\x1b[32;1;1M")},
+		{"URXVT release", terminal.MouseEncodingURXVT, 0, true, 0, 0, []byte("\x1b[35;1;1M")},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			assert.Equal(t, s.expected, encodeMouseEvent(s.encoding, s.button, s.release, s.x, s.y))
+		})
+	}
+}
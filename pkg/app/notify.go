@@ -0,0 +1,334 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// notification triggers
+const (
+	triggerMatch = "match"
+	triggerIdle  = "idle"
+	triggerExit  = "exit"
+	triggerBell  = "bell"
+)
+
+// notification actions
+const (
+	actionFlash   = "flash"
+	actionBell    = "bell"
+	actionOSC9    = "osc9"
+	actionOSC777  = "osc777"
+	actionCommand = "command"
+)
+
+// notifyCooldown stops a trigger from firing more than once a second, so that
+// a flood of matching output doesn't turn into a flood of notifications
+const notifyCooldown = time.Second
+
+// maxPartialLine is how much of a line we hold on to while waiting for the
+// rest of it to arrive
+const maxPartialLine = 4096
+
+// notifier is a config.NotificationConfig ready to fire
+type notifier struct {
+	config    config.NotificationConfig
+	pattern   *regexp.Regexp
+	lastFired time.Time
+	// for idle triggers, timer is running while we're waiting for the output
+	// to go quiet after a submission
+	timer *time.Timer
+	armed bool
+}
+
+// notifications holds the notifiers along with what they need to see the
+// output. The mutex guards everything here, since output arrives on the pty
+// goroutine and submissions on the gui goroutine.
+type notifications struct {
+	mutex     sync.Mutex
+	notifiers []*notifier
+	// partialLine is output since the last newline
+	partialLine []byte
+	// lastOutput is when output last arrived, which an idle timer that fired
+	// just as it arrived checks
+	lastOutput time.Time
+	// command is the session's command line, for command actions, which run
+	// on goroutines of their own
+	command string
+}
+
+// notificationEvent describes why a notifier fired
+type notificationEvent struct {
+	trigger string
+	message string
+	// env is passed on to command actions
+	env []string
+}
+
+// ansiPattern matches escape sequences, so we can match patterns against the
+// text the user sees
+var ansiPattern = regexp.MustCompile(`\x1b(\[[0-9;?<>=!]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[()][0-9A-Za-z]|[@-Z\\-_])`)
+
+func compileNotifications(configs []config.NotificationConfig) ([]*notifier, error) {
+	result := make([]*notifier, 0, len(configs))
+	for _, notificationConfig := range configs {
+		n := &notifier{config: notificationConfig}
+
+		switch notificationConfig.Trigger {
+		case triggerMatch:
+			pattern, err := regexp.Compile(notificationConfig.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("notification pattern '%s': %v", notificationConfig.Pattern, err)
+			}
+			n.pattern = pattern
+		case triggerIdle:
+			if notificationConfig.IdleSeconds <= 0 {
+				return nil, fmt.Errorf("idle notification needs idleseconds to be more than zero")
+			}
+		case triggerExit, triggerBell:
+		default:
+			return nil, fmt.Errorf("unknown notification trigger '%s'", notificationConfig.Trigger)
+		}
+
+		for _, action := range notificationConfig.Actions {
+			switch action {
+			case actionFlash, actionBell, actionOSC9, actionOSC777:
+			case actionCommand:
+				if notificationConfig.Command == "" {
+					return nil, fmt.Errorf("notification action 'command' needs a command")
+				}
+			default:
+				return nil, fmt.Errorf("unknown notification action '%s'", action)
+			}
+		}
+
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// notifyOutput looks for matches in the output and keeps idle timers from
+// firing while output is arriving
+func (app *App) notifyOutput(s *session, p []byte) {
+	ns := &s.notifications
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	if len(ns.notifiers) == 0 {
+		return
+	}
+
+	ns.lastOutput = time.Now()
+	for _, n := range ns.notifiers {
+		if n.armed {
+			n.timer.Reset(time.Duration(n.config.IdleSeconds) * time.Second)
+		}
+	}
+
+	if !ns.matching() {
+		return
+	}
+
+	ns.partialLine = append(ns.partialLine, p...)
+	for {
+		index := bytes.IndexByte(ns.partialLine, '\n')
+		if index == -1 {
+			break
+		}
+		app.matchLine(s, string(ns.partialLine[:index]))
+		ns.partialLine = ns.partialLine[index+1:]
+	}
+	if len(ns.partialLine) > maxPartialLine {
+		// a very long line. We'll match what we have so far
+		app.matchLine(s, string(ns.partialLine))
+		ns.partialLine = nil
+	}
+}
+
+func (ns *notifications) matching() bool {
+	for _, n := range ns.notifiers {
+		if n.pattern != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLine must be called with the notifications mutex held
+func (app *App) matchLine(s *session, line string) {
+	line = strings.TrimRight(ansiPattern.ReplaceAllString(line, ""), "\r")
+	for _, n := range s.notifications.notifiers {
+		if n.pattern == nil || !n.pattern.MatchString(line) {
+			continue
+		}
+		app.fire(s, n, notificationEvent{
+			trigger: triggerMatch,
+			message: fmt.Sprintf(app.Tr.NotifyMatch, strings.TrimSpace(line)),
+			env:     []string{"LAZYSESSION_MATCH=" + line},
+		})
+	}
+}
+
+// setCommand notes the session's command line for command actions
+func (ns *notifications) setCommand(command string) {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+	ns.command = command
+}
+
+// setNotifiers swaps in the notifiers from a reloaded config, stopping any
+// idle timers the old ones had running
+func (ns *notifications) setNotifiers(notifiers []*notifier) {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	for _, n := range ns.notifiers {
+		n.armed = false
+		if n.timer != nil {
+			n.timer.Stop()
+		}
+	}
+	ns.notifiers = notifiers
+}
+
+// notifySubmitted starts the idle timers
+func (app *App) notifySubmitted(s *session) {
+	ns := &s.notifications
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	for _, n := range ns.notifiers {
+		if n.config.Trigger != triggerIdle {
+			continue
+		}
+		n.armed = true
+		idle := time.Duration(n.config.IdleSeconds) * time.Second
+		if n.timer != nil {
+			n.timer.Reset(idle)
+			continue
+		}
+		n := n
+		n.timer = time.AfterFunc(idle, func() { app.onIdle(s, n) })
+	}
+}
+
+func (app *App) onIdle(s *session, n *notifier) {
+	ns := &s.notifications
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	if !n.armed {
+		return
+	}
+	// output that arrived while we waited for the mutex has put the timer
+	// back, so it'll fire again
+	if time.Since(ns.lastOutput) < time.Duration(n.config.IdleSeconds)*time.Second {
+		return
+	}
+	n.armed = false
+	app.fire(s, n, notificationEvent{
+		trigger: triggerIdle,
+		message: fmt.Sprintf(app.Tr.NotifyIdle, n.config.IdleSeconds),
+		env:     []string{"LAZYSESSION_IDLE_SECONDS=" + strconv.Itoa(n.config.IdleSeconds)},
+	})
+}
+
+func (app *App) notifyBell(s *session) {
+	app.notifyTrigger(s, triggerBell, notificationEvent{
+		trigger: triggerBell,
+		message: app.Tr.NotifyBell,
+	})
+}
+
+func (app *App) notifyExit(s *session, exitCode int) {
+	app.notifyTrigger(s, triggerExit, notificationEvent{
+		trigger: triggerExit,
+		message: fmt.Sprintf(app.Tr.NotifyExit, exitCode),
+		env:     []string{"LAZYSESSION_EXIT_CODE=" + strconv.Itoa(exitCode)},
+	})
+}
+
+func (app *App) notifyTrigger(s *session, trigger string, event notificationEvent) {
+	ns := &s.notifications
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	for _, n := range ns.notifiers {
+		if n.config.Trigger == trigger {
+			app.fire(s, n, event)
+		}
+	}
+}
+
+// fire carries out the notifier's actions. It must be called with the
+// notifications mutex held.
+func (app *App) fire(s *session, n *notifier, event notificationEvent) {
+	if time.Since(n.lastFired) < notifyCooldown {
+		return
+	}
+	n.lastFired = time.Now()
+
+	for _, action := range n.config.Actions {
+		switch action {
+		case actionCommand:
+			go app.runNotifyCommand(n.config.Command, s.notifications.command, event)
+		default:
+			action := action
+			// anything we write to the terminal has to go out between the
+			// gui's own writes, so we do it from the gui goroutine
+			app.g.Update(func(*gocui.Gui) error {
+				app.notifyTerminal(action, event)
+				return nil
+			})
+		}
+	}
+}
+
+func (app *App) notifyTerminal(action string, event notificationEvent) {
+	switch action {
+	case actionFlash:
+		app.renderInfo(utils.ColoredStringDirect(" "+event.message+" ", color.New(color.FgBlack, color.BgYellow)))
+	case actionBell:
+		_, _ = os.Stdout.WriteString("\a")
+	case actionOSC9:
+		_, _ = os.Stdout.WriteString("\x1b]9;" + oscSafe(event.message) + "\a")
+	case actionOSC777:
+		_, _ = os.Stdout.WriteString("\x1b]777;notify;lazysession;" + oscSafe(event.message) + "\a")
+	}
+}
+
+// oscSafe removes anything that would end an OSC sequence early
+func oscSafe(str string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, str)
+}
+
+func (app *App) runNotifyCommand(command string, commandLine string, event notificationEvent) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"LAZYSESSION_TRIGGER="+event.trigger,
+		"LAZYSESSION_MESSAGE="+event.message,
+		"LAZYSESSION_COMMAND="+commandLine,
+	)
+	cmd.Env = append(cmd.Env, event.env...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		app.Log.Errorf("notification command failed: %v: %s", err, output)
+	}
+}
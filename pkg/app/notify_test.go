@@ -0,0 +1,65 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileNotifications(t *testing.T) {
+	type scenario struct {
+		name          string
+		config        config.NotificationConfig
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"a match", config.NotificationConfig{Trigger: "match", Pattern: `FAIL|panic:`, Actions: []string{"flash", "bell"}}, ""},
+		{"an idle with a command", config.NotificationConfig{Trigger: "idle", IdleSeconds: 5, Actions: []string{"osc9", "command"}, Command: "notify-send done"}, ""},
+		{"exit and bell need nothing else", config.NotificationConfig{Trigger: "exit", Actions: []string{"osc777"}}, ""},
+		{"no actions", config.NotificationConfig{Trigger: "bell"}, ""},
+		{"a bad pattern", config.NotificationConfig{Trigger: "match", Pattern: `(`}, "notification pattern '(': error parsing regexp: missing closing ): `(`"},
+		{"an idle of no time", config.NotificationConfig{Trigger: "idle"}, "idle notification needs idleseconds to be more than zero"},
+		{"an unknown trigger", config.NotificationConfig{Trigger: "output"}, "unknown notification trigger 'output'"},
+		{"no trigger", config.NotificationConfig{Actions: []string{"flash"}}, "unknown notification trigger ''"},
+		{"a command action without a command", config.NotificationConfig{Trigger: "exit", Actions: []string{"command"}}, "notification action 'command' needs a command"},
+		{"an unknown action", config.NotificationConfig{Trigger: "exit", Actions: []string{"flash", "email"}}, "unknown notification action 'email'"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			notifiers, err := compileNotifications([]config.NotificationConfig{s.config})
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, notifiers, 1)
+			assert.Equal(t, s.config.Trigger == "match", notifiers[0].pattern != nil)
+		})
+	}
+}
+
+func TestNotificationText(t *testing.T) {
+	type scenario struct {
+		name     string
+		input    string
+		stripped string
+		oscSafe  string
+	}
+
+	scenarios := []scenario{
+		{"plain", "build done", "build done", "build done"},
+		{"colours", "\x1b[1;31mFAIL\x1b[0m: x", "FAIL: x", "[1;31mFAIL[0m: x"},
+		{"a title and a charset switch", "\x1b]0;make\x07\x1b(Bok", "ok", "]0;make(Bok"},
+		{"control characters", "a\tb\x7fc", "a\tb\x7fc", "abc"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			assert.Equal(t, s.stripped, ansiPattern.ReplaceAllString(s.input, ""))
+			assert.Equal(t, s.oscSafe, oscSafe(s.input))
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"io"
+	"time"
+)
+
+// writerFunc lets us use a function as an io.Writer
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// recordOutput passes everything the session's command prints on to the
+// recording and the transcript, if it has them
+func (app *App) recordOutput(s *session, p []byte) {
+	s.outputMutex.Lock()
+	defer s.outputMutex.Unlock()
+
+	s.lastOutput = time.Now()
+	if s.recorder != nil {
+		if err := s.recorder.Output(p); err != nil {
+			app.Log.Error(err)
+		}
+	}
+	if s.transcript != nil {
+		if _, err := s.transcript.Write(p); err != nil {
+			app.Log.Error(err)
+		}
+	}
+}
+
+// stdinWriter returns a writer that sends input to the pty, recording it along
+// the way if we're recording the session
+func (app *App) stdinWriter(s *session, ptmx io.Writer) io.Writer {
+	recorder := s.recorder
+	if recorder == nil {
+		return ptmx
+	}
+
+	return writerFunc(func(p []byte) (int, error) {
+		if err := recorder.Input(p); err != nil {
+			app.Log.Error(err)
+		}
+		return ptmx.Write(p)
+	})
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/procs"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// defaultPaneShare splits a tab down the middle
+const defaultPaneShare = 50
+
+// paneShareStep is how much growing or shrinking a pane changes its share by
+const paneShareStep = 10
+
+// rect is where a view goes, in the coordinates gocui's SetView takes
+type rect struct {
+	x0, y0, x1, y1 int
+}
+
+// size is the size of a frameless view placed at the rect
+func (r rect) size() (int, int) {
+	return r.x1 - r.x0 - 1, r.y1 - r.y0 - 1
+}
+
+// paneRect returns where the tab's pane goes. The panes of a split tab share
+// the pane area with a divider between them, unless the focused one is full
+// screen, in which case it gets the whole area to itself.
+func (app *App) paneRect(t *tab, index int) rect {
+	area := app.paneArea
+	if !t.split() || (t == app.tab && app.fullScreen) {
+		return area
+	}
+
+	if t.vertical {
+		width, _ := area.size()
+		x := area.x0 + 1 + clampPaneSize((width-1)*t.share/100, width-1)
+		if index == 0 {
+			return rect{area.x0, area.y0, x, area.y1}
+		}
+		return rect{x, area.y0, area.x1, area.y1}
+	}
+
+	_, height := area.size()
+	y := area.y0 + 1 + clampPaneSize((height-1)*t.share/100, height-1)
+	if index == 0 {
+		return rect{area.x0, area.y0, area.x1, y}
+	}
+	return rect{area.x0, y, area.x1, area.y1}
+}
+
+// clampPaneSize keeps both panes at least a row or column big
+func clampPaneSize(size int, total int) int {
+	if size > total-1 {
+		size = total - 1
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// layoutPanes places the unfocused pane of a split tab, and the divider
+// between it and the focused pane in the main view
+func (app *App) layoutPanes(g *gocui.Gui) error {
+	t := app.tab
+	if !t.split() || app.fullScreen {
+		for _, name := range []string{"pane", "divider"} {
+			if err := g.DeleteView(name); err != nil && err.Error() != "unknown view" {
+				return err
+			}
+		}
+		app.views.pane = nil
+		app.views.divider = nil
+		return nil
+	}
+
+	r := app.paneRect(t, 1-t.focused)
+	v, err := g.SetView("pane", r.x0, r.y0, r.x1, r.y1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = false
+		v.Wrap = false
+		v.Autoscroll = false
+		app.views.pane = v
+		// the view starts off empty, whatever we drew for the session before
+		t.panes[1-t.focused].mainView.rendered = false
+	}
+
+	first := app.paneRect(t, 0)
+	divider := rect{first.x0, first.y1 - 1, first.x1, first.y1 + 1}
+	if t.vertical {
+		divider = rect{first.x1 - 1, first.y0, first.x1 + 1, first.y1}
+	}
+	v, err = g.SetView("divider", divider.x0, divider.y0, divider.x1, divider.y1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = false
+		v.Wrap = false
+		app.views.divider = v
+	}
+	width, height := v.Size()
+	v.Clear()
+	if t.vertical {
+		fmt.Fprint(v, strings.TrimSuffix(strings.Repeat("│\n", height), "\n"))
+	} else {
+		fmt.Fprint(v, strings.Repeat("─", width))
+	}
+	return nil
+}
+
+// renderPane draws the unfocused pane of a split tab
+func (app *App) renderPane() error {
+	if app.views.pane == nil {
+		return nil
+	}
+	return app.renderSession(app.views.pane, app.tab.panes[1-app.tab.focused], false)
+}
+
+// openSplitPrompt asks for a command to run alongside the focused one. A tab
+// that's already split is just turned the other way.
+func (app *App) openSplitPrompt(vertical bool) func() error {
+	return func() error {
+		if app.player != nil {
+			return nil
+		}
+		t := app.tab
+		if t.split() {
+			t.vertical = vertical
+			return nil
+		}
+		return app.createPrompt(app.Tr.SplitPaneTitle, "", func(command string) error {
+			return app.splitPane(vertical, command)
+		})
+	}
+}
+
+func (app *App) splitPane(vertical bool, command string) error {
+	t := app.tab
+	if t.split() {
+		return nil
+	}
+
+	t.vertical = vertical
+	t.share = defaultPaneShare
+	// we size the new session's screen before it exists, so the pane it goes
+	// in is worked out with the focused session standing in for it
+	t.panes = append(t.panes, app.session)
+	width, height := app.paneRect(t, 1).size()
+	t.panes = t.panes[:1]
+
+	s, err := app.startSession(command, width, height)
+	if err != nil {
+		return app.renderError(err)
+	}
+	t.panes = append(t.panes, s)
+	return app.focusPane(1)
+}
+
+// focusPane makes the given pane of the current tab the one that the buffer
+// and the keyboard send to
+func (app *App) focusPane(index int) error {
+	if err := app.exitCopyMode(); err != nil {
+		return err
+	}
+
+	t := app.tab
+	t.focused = index
+	for _, s := range t.panes {
+		// the panes may have swapped views
+		s.mainView.rendered = false
+	}
+	app.session = t.panes[index]
+
+	if err := app.showSession(); err != nil {
+		return err
+	}
+	if t.split() {
+		app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.FocusedPane, app.session.name, app.keyHint("global", "prefixMenu"), app.keyHint("global", "closePane")), color.FgGreen))
+	}
+	return nil
+}
+
+func (app *App) focusOtherPane() error {
+	if !app.tab.split() {
+		return nil
+	}
+	return app.focusPane(1 - app.tab.focused)
+}
+
+// swapPanes swaps where the panes are, with focus following the session
+func (app *App) swapPanes() error {
+	t := app.tab
+	if !t.split() {
+		return nil
+	}
+	t.panes[0], t.panes[1] = t.panes[1], t.panes[0]
+	t.share = 100 - t.share
+	return app.focusPane(1 - t.focused)
+}
+
+// growPane gives the focused pane more of the tab, taking it from the other
+func (app *App) growPane(step int) func() error {
+	return func() error {
+		t := app.tab
+		if !t.split() {
+			return nil
+		}
+		if t.focused == 0 {
+			t.share += step
+		} else {
+			t.share -= step
+		}
+		if t.share < paneShareStep {
+			t.share = paneShareStep
+		}
+		if t.share > 100-paneShareStep {
+			t.share = 100 - paneShareStep
+		}
+		return nil
+	}
+}
+
+// prefixMenuItems lists what can be done with panes, and with the session as a
+// whole. Each item's key picks it straight away while the menu is open, so F12
+// works like tmux's prefix key.
+func (app *App) prefixMenuItems() []*menuItem {
+	items := []*menuItem{
+		{key: '%', label: app.Tr.SplitSideBySide, onPress: app.openSplitPrompt(true)},
+		{key: '"', label: app.Tr.SplitAboveBelow, onPress: app.openSplitPrompt(false)},
+		{key: 'o', label: app.Tr.FocusOtherPane, onPress: app.focusOtherPane},
+		{key: 's', label: app.Tr.SwapPanes, onPress: app.swapPanes},
+		{key: '+', label: app.Tr.GrowPane, onPress: app.growPane(paneShareStep)},
+		{key: '-', label: app.Tr.ShrinkPane, onPress: app.growPane(-paneShareStep)},
+	}
+	items = append(items, &menuItem{key: '?', label: app.Tr.ShowKeybindings, onPress: app.openHelp})
+	if procs.Supported() {
+		items = append(items, &menuItem{key: 'p', label: app.Tr.ShowProcesses, onPress: app.openProcessTree})
+	}
+	if app.config.SocketPath != "" {
+		items = append(items, &menuItem{key: 'd', label: app.Tr.Detach, onPress: app.detach})
+	}
+	return items
+}
+
+func (app *App) openPrefixMenu() error {
+	if app.player != nil {
+		return nil
+	}
+	return app.createMenu(app.Tr.PrefixMenuTitle, app.prefixMenuItems())
+}
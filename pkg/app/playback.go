@@ -0,0 +1,184 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/asciicast"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+const playbackInterval = time.Millisecond * 20
+const seekSeconds = 5.0
+
+// player replays an asciicast recording into the main view
+type player struct {
+	recording *asciicast.Recording
+	mutex     sync.Mutex
+	speed     float64
+	paused    bool
+	// position is how many seconds into the recording we are
+	position float64
+	// seekTo is where the user wants to jump to, or -1
+	seekTo float64
+	// next is the index of the next event to play
+	next int
+}
+
+// Play replays the recording at the given path
+func (app *App) Play(path string, speed float64) error {
+	recording, err := asciicast.Load(path)
+	if err != nil {
+		return err
+	}
+
+	if speed <= 0 {
+		speed = 1
+	}
+
+	app.player = &player{
+		recording: recording,
+		speed:     speed,
+		seekTo:    -1,
+	}
+	// we replay onto a screen the size of the recorded terminal
+	s := &session{name: filepath.Base(path), recordedCommand: recording.Header.Command, historyIndex: -1}
+	theme, err := parseTheme(app.config.UserConfig.Gui.Theme)
+	if err != nil {
+		return err
+	}
+	s.theme = theme
+	s.screen = app.newScreen(s, recording.Header.Width, recording.Header.Height)
+	app.tabs = []*tab{newTab(s)}
+	app.tab = app.tabs[0]
+	app.session = s
+
+	return app.runGui()
+}
+
+func (app *App) runPlayer() {
+	p := app.player
+
+	ticker := time.NewTicker(playbackInterval)
+	defer ticker.Stop()
+
+	status := ""
+	for range ticker.C {
+		p.mutex.Lock()
+		if p.seekTo >= 0 {
+			if p.seekTo < p.position {
+				// the screen can't be rewound so we replay everything up to the new position
+				app.session.screen.Reset()
+				app.session.screen.Resize(p.recording.Header.Width, p.recording.Header.Height)
+				p.next = 0
+			}
+			p.position = p.seekTo
+			p.seekTo = -1
+		} else if !p.paused {
+			p.position += playbackInterval.Seconds() * p.speed
+		}
+		if p.position >= p.recording.Duration() {
+			p.position = p.recording.Duration()
+			p.paused = true
+		}
+
+		events := []asciicast.Event{}
+		for p.next < len(p.recording.Events) && p.recording.Events[p.next].Time <= p.position {
+			events = append(events, p.recording.Events[p.next])
+			p.next++
+		}
+		newStatus := app.playbackStatus()
+		p.mutex.Unlock()
+
+		for _, event := range events {
+			switch event.Type {
+			case asciicast.Output:
+				_, _ = app.session.screen.Write([]byte(event.Data))
+			case asciicast.Resize:
+				var width, height int
+				if _, err := fmt.Sscanf(event.Data, "%dx%d", &width, &height); err == nil {
+					app.session.screen.Resize(width, height)
+				}
+			}
+		}
+		if len(events) > 0 {
+			app.requestRender()
+		}
+
+		if newStatus != status {
+			status = newStatus
+			app.g.Update(func(*gocui.Gui) error {
+				app.renderInfo(newStatus)
+				return nil
+			})
+		}
+	}
+}
+
+// playbackStatus must be called with the player's mutex held
+func (app *App) playbackStatus() string {
+	p := app.player
+
+	state := app.Tr.Playing
+	if p.paused {
+		state = app.Tr.Paused
+	}
+
+	return utils.ColoredString(fmt.Sprintf(
+		"%s %.1fs / %.1fs (%gx) ",
+		state,
+		p.position,
+		p.recording.Duration(),
+		p.speed,
+	), color.FgGreen) + app.Tr.PlaybackKeys
+}
+
+func (app *App) withPlayer(f func(p *player)) error {
+	if app.player == nil {
+		return nil
+	}
+	app.player.mutex.Lock()
+	defer app.player.mutex.Unlock()
+	f(app.player)
+	return nil
+}
+
+func (app *App) togglePlaybackPause() error {
+	return app.withPlayer(func(p *player) {
+		if p.paused && p.position >= p.recording.Duration() {
+			// play again from the start
+			p.seekTo = 0
+		}
+		p.paused = !p.paused
+	})
+}
+
+func (app *App) playbackFaster() error {
+	return app.withPlayer(func(p *player) { p.speed *= 2 })
+}
+
+func (app *App) playbackSlower() error {
+	return app.withPlayer(func(p *player) { p.speed /= 2 })
+}
+
+func (app *App) seekBackward() error {
+	return app.withPlayer(func(p *player) {
+		p.seekTo = p.position - seekSeconds
+		if p.seekTo < 0 {
+			p.seekTo = 0
+		}
+	})
+}
+
+func (app *App) seekForward() error {
+	return app.withPlayer(func(p *player) {
+		p.seekTo = p.position + seekSeconds
+		if p.seekTo > p.recording.Duration() {
+			p.seekTo = p.recording.Duration()
+		}
+	})
+}
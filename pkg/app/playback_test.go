@@ -0,0 +1,37 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/jesseduffield/lazysession/pkg/asciicast"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+	"github.com/stretchr/testify/assert"
+)
+
+// a session replaying a recording has no command of its own, so anything that
+// would look at it has to make do with the recording
+func TestPlaybackSession(t *testing.T) {
+	recording := &asciicast.Recording{Header: asciicast.Header{Version: 2, Width: 20, Height: 5, Command: "vim notes.txt"}}
+	s := &session{name: "notes.cast", recordedCommand: recording.Header.Command, historyIndex: -1}
+	s.screen = terminal.New(20, 5)
+	_, _ = s.screen.Write([]byte("hello\r\n"))
+	app := &App{player: &player{recording: recording, seekTo: -1}, session: s}
+	app.tabs = []*tab{newTab(s)}
+	app.tab = app.tabs[0]
+
+	assert.Equal(t, "vim notes.txt", s.commandLine())
+	assert.Equal(t, "vim notes.txt", app.commandSegment())
+	assert.Equal(t, "vim notes.txt", s.highlightCommand(nil, s.screen.LineCount(), 0))
+
+	html := app.scrollbackExport(exportHTML, false)
+	assert.Contains(t, html, "<title>vim notes.txt</title>")
+	assert.Contains(t, html, "hello")
+
+	assert.NoError(t, app.toggleTranscript())
+	assert.Nil(t, s.transcript)
+	assert.NoError(t, app.restartCommand())
+	assert.NoError(t, app.openNewTabPrompt())
+	assert.NoError(t, app.openSplitPrompt(true)())
+	assert.NoError(t, app.closePane())
+	assert.Len(t, app.tabs, 1)
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// profile is a config.ProfileConfig ready to run
+type profile struct {
+	name          string
+	config        config.ProfileConfig
+	promptPattern *regexp.Regexp
+	theme         guiTheme
+}
+
+// compileProfiles checks the user's profiles and gets them ready to run
+func compileProfiles(profiles map[string]config.ProfileConfig, theme config.ThemeConfig) (map[string]*profile, error) {
+	result := make(map[string]*profile, len(profiles))
+	for name, p := range profiles {
+		compiled, err := compileProfile(name, p, theme)
+		if err != nil {
+			return nil, fmt.Errorf("profile '%s': %v", name, err)
+		}
+		result[name] = compiled
+	}
+	return result, nil
+}
+
+func compileProfile(name string, p config.ProfileConfig, theme config.ThemeConfig) (*profile, error) {
+	if p.Command == "" {
+		return nil, fmt.Errorf("there's no command to run")
+	}
+
+	result := &profile{name: name, config: p}
+	if p.PromptPattern != "" {
+		promptPattern, err := regexp.Compile(p.PromptPattern)
+		if err != nil {
+			return nil, fmt.Errorf("prompt pattern: %v", err)
+		}
+		result.promptPattern = promptPattern
+	}
+
+	parsed, err := parseTheme(mergeThemes(theme, p.Theme))
+	if err != nil {
+		return nil, err
+	}
+	result.theme = parsed
+	return result, nil
+}
+
+// profileNames returns the names of the user's profiles in alphabetical order
+func (app *App) profileNames() []string {
+	names := make([]string, 0, len(app.profiles))
+	for name := range app.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cmd makes the command the profile runs. Its environment is set when it
+// starts, by commandEnv.
+func (p *profile) cmd() *exec.Cmd {
+	cmd := exec.Command(p.config.Command, p.config.Args...)
+	cmd.Dir = expandHome(p.config.Dir)
+	return cmd
+}
+
+// commandLine is how the profile's command would be typed into a shell
+func (p *profile) commandLine() string {
+	return strings.Join(append([]string{p.config.Command}, p.config.Args...), " ")
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// newProfileSession gets the profile ready to run in a tab of its own
+func (app *App) newProfileSession(p *profile) (*session, error) {
+	s, err := app.newSession(p.cmd())
+	if err != nil {
+		return nil, err
+	}
+	applyProfile(s, p)
+	return s, nil
+}
+
+// applyProfile sets the session up to run the profile. It has to happen
+// before the command starts.
+func applyProfile(s *session, p *profile) {
+	s.cmd = p.cmd()
+	s.notifications.setCommand(s.commandLine())
+	s.profile = p
+	s.name = p.name
+	s.historyNamespace = p.config.HistoryNamespace
+	if s.historyNamespace == "" {
+		s.historyNamespace = filepath.Base(p.config.Command)
+	}
+	s.promptPattern = p.promptPattern
+	s.lineTerminator = defaultLineTerminator
+	if p.config.LineTerminator != "" {
+		s.lineTerminator = p.config.LineTerminator
+	}
+	s.theme = p.theme
+}
+
+// openProfilePicker asks which profile to run when we're started without a
+// command. There's nothing to go back to, so closing it quits.
+func (app *App) openProfilePicker() error {
+	names := app.profileNames()
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+
+	items := make([]*menuItem, len(names))
+	for i, name := range names {
+		p := app.profiles[name]
+		items[i] = &menuItem{
+			label:   utils.WithPadding(name, width+2) + p.commandLine(),
+			onPress: func() error { return app.pickProfile(p) },
+		}
+	}
+
+	if err := app.createMenu(app.Tr.PickProfileTitle, items); err != nil {
+		return err
+	}
+	app.menu.onCancel = app.quit
+	return nil
+}
+
+// pickProfile starts the profile in the session we've been holding for it
+func (app *App) pickProfile(p *profile) error {
+	s := app.session
+	app.pickingProfile = false
+	applyProfile(s, p)
+	if err := app.prepareFirstSession(s); err != nil {
+		return err
+	}
+
+	app.startCommand(s)
+	return app.showSession()
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jesseduffield/gocui"
+)
+
+// promptState is for a popup asking the user to type something in, e.g. the
+// name of a tab
+type promptState struct {
+	title     string
+	initial   string
+	onConfirm func(string) error
+	prevView  string
+}
+
+func (app *App) createPrompt(title string, initial string, onConfirm func(string) error) error {
+	prevView := "main"
+	if currentView := app.g.CurrentView(); currentView != nil && currentView.Name() != "prompt" {
+		prevView = currentView.Name()
+	} else if app.prompt != nil {
+		prevView = app.prompt.prevView
+	}
+
+	app.prompt = &promptState{
+		title:     title,
+		initial:   initial,
+		onConfirm: onConfirm,
+		prevView:  prevView,
+	}
+
+	if err := app.layoutPrompt(app.g); err != nil {
+		return err
+	}
+	_, err := app.g.SetCurrentView("prompt")
+	return err
+}
+
+func (app *App) closePrompt() error {
+	if app.prompt == nil {
+		return nil
+	}
+
+	prevView := app.prompt.prevView
+	app.prompt = nil
+	app.views.prompt = nil
+
+	if err := app.g.DeleteView("prompt"); err != nil {
+		return err
+	}
+	_, err := app.g.SetCurrentView(prevView)
+	return err
+}
+
+func (app *App) confirmPrompt() error {
+	if app.prompt == nil {
+		return nil
+	}
+
+	onConfirm := app.prompt.onConfirm
+	input := ""
+	if app.views.prompt != nil {
+		input = strings.TrimSpace(app.views.prompt.Buffer())
+	}
+	if err := app.closePrompt(); err != nil {
+		return err
+	}
+	return onConfirm(input)
+}
+
+// layoutPrompt centres the prompt over the main view
+func (app *App) layoutPrompt(g *gocui.Gui) error {
+	if app.prompt == nil {
+		return nil
+	}
+
+	width, height := g.Size()
+	promptWidth := width / 2
+	if promptWidth < len(app.prompt.title)+4 {
+		promptWidth = len(app.prompt.title) + 4
+	}
+	if promptWidth > width-2 {
+		promptWidth = width - 2
+	}
+
+	x0 := (width - promptWidth) / 2
+	y0 := height / 2
+	v, err := g.SetView("prompt", x0, y0-1, x0+promptWidth+1, y0+1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = true
+		v.Editable = true
+		fmt.Fprint(v, app.prompt.initial)
+		app.views.prompt = v
+	}
+	v.Title = app.prompt.title
+
+	_, err = g.SetViewOnTop("prompt")
+	return err
+}
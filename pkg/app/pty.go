@@ -1,19 +1,21 @@
 package app
 
 import (
-	"fmt"
 	"io"
+	"os"
+	"os/exec"
 	"strconv"
+	"time"
 	"unicode/utf8"
 
 	"github.com/davecgh/go-spew/spew"
-	"github.com/fatih/color"
 	"github.com/jesseduffield/gocui"
-	"github.com/jesseduffield/lazysession/pkg/utils"
 	"github.com/jesseduffield/pty"
 	"github.com/sirupsen/logrus"
 )
 
+const ptyReadSize = 64 * 1024
+
 type inputReader struct {
 	innerReader io.Reader
 	log         *logrus.Entry
@@ -40,27 +42,71 @@ func (r *inputReader) Read(buf []byte) (int, error) {
 	return n, err
 }
 
-func (app *App) runCommandInPty(view *gocui.View) error {
-	view.Pty = true
-
-	ptmx, err := pty.Start(app.cmd)
+// runCommandInPty runs the session's command until it exits, feeding what it
+// prints into the session's screen, and returns how it exited. What the gui
+// needs to know about the run is handed over on the gui goroutine.
+func (app *App) runCommandInPty(s *session) (exitStatus, error) {
+	s.cmd.Env = app.commandEnv(s)
+	ptmx, err := pty.Start(s.cmd)
 	if err != nil {
-		return err
+		return exitStatus{}, err
 	}
 	// Make sure to close the pty at the end.
 	defer func() { _ = ptmx.Close() }() // Best effort.
 
-	app.ptmx = ptmx
-	app.onResize()
+	started := time.Now()
+	if err := app.resizePty(ptmx, s); err != nil {
+		app.Log.Error(err)
+	}
 
-	view.StdinWriter = ptmx
+	stdin := app.stdinWriter(s, ptmx)
+	app.g.Update(func(*gocui.Gui) error {
+		s.ptmx = ptmx
+		s.commandStarted = started
+		s.stdin = stdin
+		// the screen may have been resized since we sized the pty
+		if err := app.resizePty(ptmx, s); err != nil {
+			app.Log.Error(err)
+		}
+		if s != app.session {
+			return nil
+		}
+		return app.showSession()
+	})
 
-	_, _ = io.Copy(view, ptmx)
+	// replies to queries like cursor position reports go straight back to the program
+	s.screen.SetResponseWriter(ptmx)
+	// we read as much as we can at once, and only draw what's on the screen by
+	// the time the next frame is due
+	buf := make([]byte, ptyReadSize)
+	for {
+		n, err := ptmx.Read(buf)
+		if n > 0 {
+			app.recordOutput(s, buf[:n])
+			_, _ = s.screen.Write(buf[:n])
+			s.matchPrompt()
+			s.updateCwd()
+			app.notifyOutput(s, buf[:n])
+			app.requestRender()
+		}
+		if err != nil {
+			break
+		}
+	}
 
-	app.views.info.Clear()
-	fmt.Fprintf(app.views.info, utils.ColoredString("command has exited, press 'q' to quit", color.FgGreen))
+	if err := s.cmd.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			app.Log.Error(err)
+		}
+	}
+	status := newExitStatus(s.cmd)
+	app.notifyExit(s, status.exitCode())
 
-	view.Pty = false
+	return status, nil
+}
 
-	return nil
+// resizePty tells the session's command how big its terminal is
+func (app *App) resizePty(ptmx *os.File, s *session) error {
+	width, height := s.screen.Size()
+	return pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(width), Rows: uint16(height)})
 }
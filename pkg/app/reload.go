@@ -0,0 +1,158 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/lazysession/pkg/config"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// configPollInterval is how often we look at whether config.yml has changed
+const configPollInterval = time.Second
+
+// fileStamp is enough to tell that a file has changed. It's the zero value
+// for a file that isn't there.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// watchConfig reloads the user's config whenever config.yml changes. We go by
+// the file's path rather than the file, because editors often save by
+// replacing the file with a new one.
+func (app *App) watchConfig() {
+	path := app.config.UserConfigPath()
+	last := statFile(path)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		stamp := statFile(path)
+		if stamp == last {
+			continue
+		}
+		last = stamp
+		app.update(app.reloadConfig)
+	}
+}
+
+// reloadConfig puts the user's config back in place after it's been edited,
+// with its theme, keybindings, highlight rules, notifications, status line,
+// timestamps and scrollback limits. If there's anything wrong with it we say
+// so and keep the config we have. The rest only takes effect on restart,
+// which we also say if it's changed.
+func (app *App) reloadConfig() error {
+	userConfig, err := app.config.ReloadUserConfig()
+	if err != nil {
+		return app.renderConfigError(err)
+	}
+
+	if err := validateRestartPolicy(userConfig.Restart.Policy); err != nil {
+		return app.renderConfigError(err)
+	}
+	highlightRules, err := compileHighlightRules(userConfig.Highlights)
+	if err != nil {
+		return app.renderConfigError(err)
+	}
+	statusLine, err := parseStatusLine(userConfig.Gui.StatusLine)
+	if err != nil {
+		return app.renderConfigError(err)
+	}
+	gutter, err := parseGutterMode(userConfig.Gui.Timestamps)
+	if err != nil {
+		return app.renderConfigError(err)
+	}
+	profiles, err := compileProfiles(userConfig.Profiles, userConfig.Gui.Theme)
+	if err != nil {
+		return app.renderConfigError(err)
+	}
+	themes := map[*session]guiTheme{}
+	notifiers := map[*session][]*notifier{}
+	for _, s := range app.sessions() {
+		theme := userConfig.Gui.Theme
+		if s.profile != nil {
+			theme = mergeThemes(theme, s.profile.config.Theme)
+		}
+		if themes[s], err = parseTheme(theme); err != nil {
+			return app.renderConfigError(err)
+		}
+		// notifiers keep track of when they last fired, so each session needs
+		// its own
+		if notifiers[s], err = compileNotifications(userConfig.Notifications); err != nil {
+			return app.renderConfigError(err)
+		}
+	}
+	bindings, err := app.makeKeybindings(userConfig.Keybindings)
+	if err != nil {
+		return app.renderConfigError(err)
+	}
+
+	needRestart := restartKeys(app.config.UserConfig, userConfig)
+	// the timestamps key says what the gutter starts off showing, so we leave
+	// it as the user toggled it unless that's what's changed
+	if userConfig.Gui.Timestamps != app.config.UserConfig.Gui.Timestamps {
+		app.gutter = gutter
+	}
+	app.config.UserConfig = userConfig
+	app.highlightRules = highlightRules
+	app.statusLine = statusLine
+	app.profiles = profiles
+	for s, theme := range themes {
+		s.theme = theme
+		s.mainView.rendered = false
+		// a recording being replayed has no notifications or scrollback limit
+		if app.player == nil {
+			s.notifications.setNotifiers(notifiers[s])
+			if s.screen != nil {
+				s.screen.SetScrollbackLimit(userConfig.Scrollback.Lines, userConfig.Scrollback.Bytes)
+			}
+		}
+	}
+	app.applyTheme(app.session.theme)
+	if err := app.setKeybindings(bindings); err != nil {
+		return err
+	}
+	if app.player == nil {
+		app.startStatusLine()
+	}
+
+	if len(needRestart) > 0 {
+		app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.ConfigNeedsRestart, strings.Join(needRestart, ", ")), color.FgYellow))
+		return nil
+	}
+	app.renderInfo(utils.ColoredString(app.Tr.ConfigReloaded, color.FgGreen))
+	return nil
+}
+
+// restartKeys names the keys that have changed but only take effect when we
+// next start
+func restartKeys(previous *config.UserConfig, next *config.UserConfig) []string {
+	keys := []string{}
+	if previous.Gui.MaxFPS != next.Gui.MaxFPS {
+		keys = append(keys, "gui.maxfps")
+	}
+	if previous.Scrollback.Spill != next.Scrollback.Spill {
+		keys = append(keys, "scrollback.spill")
+	}
+	return keys
+}
+
+// renderConfigError says what's wrong with the config, all on one line since
+// that's all the info view has
+func (app *App) renderConfigError(err error) error {
+	message := strings.Replace(err.Error(), ":\n  ", ": ", 1)
+	message = strings.ReplaceAll(message, "\n  ", "; ")
+	return app.renderError(fmt.Errorf(app.Tr.ConfigNotReloaded, app.config.UserConfigPath(), message))
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"time"
+
+	"github.com/jesseduffield/gocui"
+)
+
+// defaultMaxFPS is used when the user config doesn't set a frame rate
+const defaultMaxFPS = 60
+
+// renderLoop redraws the gui when something outside of it has changed, such as
+// the program printing output. Requests are coalesced so that we draw at most
+// maxFPS frames per second however fast they come in, and we never have more
+// than one frame queued up, so that keypresses get a look in during floods.
+type renderLoop struct {
+	requests chan struct{}
+	interval time.Duration
+}
+
+func newRenderLoop(maxFPS int) *renderLoop {
+	if maxFPS <= 0 {
+		maxFPS = defaultMaxFPS
+	}
+	return &renderLoop{
+		// a request made while one is pending has nothing to add
+		requests: make(chan struct{}, 1),
+		interval: time.Second / time.Duration(maxFPS),
+	}
+}
+
+// request asks for a redraw. It never blocks.
+func (r *renderLoop) request() {
+	select {
+	case r.requests <- struct{}{}:
+	default:
+	}
+}
+
+// run draws a frame for each batch of requests until the gui is closed
+func (r *renderLoop) run(g *gocui.Gui) {
+	drawn := make(chan struct{})
+	lastFrame := time.Time{}
+	for range r.requests {
+		if wait := r.interval - time.Since(lastFrame); wait > 0 {
+			// anything requested while we wait is covered by this frame
+			time.Sleep(wait)
+		}
+		select {
+		case <-r.requests:
+		default:
+		}
+
+		lastFrame = time.Now()
+		// gocui redraws everything after handling an update
+		g.Update(func(*gocui.Gui) error {
+			drawn <- struct{}{}
+			return nil
+		})
+		<-drawn
+	}
+}
+
+// requestRender redraws the gui soon, for when something has changed outside
+// of a keybinding handler
+func (app *App) requestRender() {
+	if app.renderLoop != nil {
+		app.renderLoop.request()
+	}
+}
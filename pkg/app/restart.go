@@ -0,0 +1,181 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jesseduffield/gocui"
+	"github.com/mattn/go-runewidth"
+)
+
+// restart policies
+const (
+	restartNever     = "never"
+	restartAlways    = "always"
+	restartOnFailure = "on-failure"
+	restartBackoff   = "backoff"
+)
+
+// exitStatus is how the wrapped program exited: with an exit code, or killed
+// by a signal
+type exitStatus struct {
+	code   int
+	signal syscall.Signal
+}
+
+func newExitStatus(cmd *exec.Cmd) exitStatus {
+	state := cmd.ProcessState
+	if state == nil {
+		return exitStatus{code: -1}
+	}
+	if waitStatus, ok := state.Sys().(syscall.WaitStatus); ok && waitStatus.Signaled() {
+		return exitStatus{signal: waitStatus.Signal()}
+	}
+	return exitStatus{code: state.ExitCode()}
+}
+
+// exitCode is the code a shell would report, which for a signal is 128 plus
+// the signal's number
+func (s exitStatus) exitCode() int {
+	if s.signal != 0 {
+		return 128 + int(s.signal)
+	}
+	return s.code
+}
+
+func (s exitStatus) failed() bool {
+	return s.exitCode() != 0
+}
+
+func validateRestartPolicy(policy string) error {
+	switch policy {
+	case "", restartNever, restartAlways, restartOnFailure, restartBackoff:
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown restart policy '%s', expected one of %s",
+		policy,
+		strings.Join([]string{restartNever, restartAlways, restartOnFailure, restartBackoff}, ", "),
+	)
+}
+
+// exitMessage describes how the session's command exited
+func (app *App) exitMessage(s *session) string {
+	status := s.exitStatus
+	if status.signal != 0 {
+		return fmt.Sprintf(app.Tr.CommandKilled, int(status.signal), status.signal)
+	}
+	return fmt.Sprintf(app.Tr.CommandExited, status.code)
+}
+
+// scheduleRestart starts the session's command again after a delay if the
+// restart policy says to. It must be called from the gui goroutine.
+func (app *App) scheduleRestart(s *session) {
+	restartConfig := app.config.UserConfig.Restart
+	switch restartConfig.Policy {
+	case restartAlways:
+	case restartOnFailure, restartBackoff:
+		if !s.exitStatus.failed() {
+			return
+		}
+	default:
+		return
+	}
+
+	delay := time.Duration(restartConfig.DelaySeconds) * time.Second
+	if restartConfig.Policy == restartBackoff {
+		maxDelay := time.Duration(restartConfig.MaxDelaySeconds) * time.Second
+		// a command that stayed up for a good while has recovered, so we
+		// start counting again
+		if time.Since(s.commandStarted) > maxDelay {
+			s.backoff = 0
+		}
+		if s.backoff == 0 {
+			s.backoff = delay
+		} else {
+			s.backoff *= 2
+		}
+		if s.backoff > maxDelay {
+			s.backoff = maxDelay
+		}
+		delay = s.backoff
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		app.g.Update(func(*gocui.Gui) error {
+			// the user may have restarted it themselves in the meantime, or
+			// closed the tab
+			if s.restartTimer != timer || s.closed {
+				return nil
+			}
+			return app.restartSession(s)
+		})
+	})
+	s.restartTimer = timer
+	s.restartAt = time.Now().Add(delay)
+}
+
+// restartCommand restarts the command in the current tab
+func (app *App) restartCommand() error {
+	return app.restartSession(app.session)
+}
+
+// restartSession runs the session's command again with the same args and
+// working directory, below what it printed last time
+func (app *App) restartSession(s *session) error {
+	if !s.exited || app.player != nil {
+		return nil
+	}
+	if s.restartTimer != nil {
+		s.restartTimer.Stop()
+		s.restartTimer = nil
+	}
+
+	cmd := exec.Command(s.cmd.Path)
+	cmd.Args = s.cmd.Args
+	cmd.Dir = s.cmd.Dir
+	s.cmd = cmd
+
+	app.writeRestartSeparator(s)
+	s.exited = false
+	s.stdin = nil
+	s.ptmx = nil
+	if s == app.session {
+		if err := app.showSession(); err != nil {
+			return err
+		}
+	}
+
+	app.startCommand(s)
+	return nil
+}
+
+// writeRestartSeparator marks where the old run's output ends. It also puts
+// back any modes the old run left behind, so the new one starts afresh.
+func (app *App) writeRestartSeparator(s *session) {
+	width, _ := s.screen.Size()
+	label := " " + fmt.Sprintf(app.Tr.RestartSeparator, time.Now().Format("15:04:05")) + " "
+	rule := "──" + label
+	if fill := width - runewidth.StringWidth(rule); fill > 0 {
+		rule += strings.Repeat("─", fill)
+	}
+
+	separator := "\x1b[!p\x1b[?1000l\x1b[?1002l\x1b[?1003l\x1b[?1004l\x1b[?1006l\x1b[?2004l"
+	if s.screen.AltScreen() {
+		// leaving the alternate screen also puts the cursor back where it was
+		separator = "\x1b[?1049l" + separator
+	}
+	if x, _ := s.screen.Cursor(); x != 0 {
+		separator += "\r\n"
+	}
+	separator += "\x1b[2m" + rule + "\x1b[m\r\n"
+
+	app.recordOutput(s, []byte(separator))
+	_, _ = s.screen.Write([]byte(separator))
+	s.mainView.scrollOffset = 0
+	app.requestRender()
+}
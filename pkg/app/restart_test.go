@@ -0,0 +1,60 @@
+package app
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRestartPolicy(t *testing.T) {
+	type scenario struct {
+		policy        string
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"", ""},
+		{"never", ""},
+		{"always", ""},
+		{"on-failure", ""},
+		{"backoff", ""},
+		{"onfailure", "unknown restart policy 'onfailure', expected one of never, always, on-failure, backoff"},
+		{"Always", "unknown restart policy 'Always', expected one of never, always, on-failure, backoff"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.policy, func(t *testing.T) {
+			err := validateRestartPolicy(s.policy)
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	type scenario struct {
+		name     string
+		status   exitStatus
+		exitCode int
+		failed   bool
+	}
+
+	scenarios := []scenario{
+		{"success", exitStatus{code: 0}, 0, false},
+		{"failure", exitStatus{code: 2}, 2, true},
+		{"couldn't start", exitStatus{code: -1}, -1, true},
+		{"killed", exitStatus{signal: syscall.SIGKILL}, 137, true},
+		{"interrupted", exitStatus{signal: syscall.SIGINT}, 130, true},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			assert.Equal(t, s.exitCode, s.status.exitCode())
+			assert.Equal(t, s.failed, s.status.failed())
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+)
+
+const scrollbackDirname = "scrollback"
+
+// spillCount is how many spill files we've created
+var spillCount int
+
+// mainViewState keeps track of what we've drawn in the main view, so that we
+// only redraw it when the screen has changed or the user has scrolled
+type mainViewState struct {
+	// scrollOffset is how many lines up from the bottom we've scrolled. When
+	// it's zero we follow the output.
+	scrollOffset   int
+	renderedChange uint64
+	renderedOffset int
+	rendered       bool
+}
+
+// newScreen creates the emulated terminal that the wrapped program writes to
+func (app *App) newScreen(s *session, width, height int) *terminal.Screen {
+	screen := terminal.New(width, height)
+	if app.player == nil {
+		scrollbackConfig := app.config.UserConfig.Scrollback
+		screen.SetScrollbackLimit(scrollbackConfig.Lines, scrollbackConfig.Bytes)
+		if s.spill != nil {
+			screen.SetSpill(s.spill)
+		}
+		screen.OnOSC = s.handleOSC
+		screen.OnBell = func() { app.notifyBell(s) }
+		screen.OnAltScreen = func(active bool) { app.onAltScreen(s, active) }
+	}
+	return screen
+}
+
+// startSpill creates the file that lines go to when they fall out of the
+// session's scrollback
+func (app *App) startSpill(s *session) error {
+	dir := filepath.Join(app.config.ConfigDir, scrollbackDirname)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// one program can have several sessions, so we number them
+	spillCount++
+	path := filepath.Join(dir, fmt.Sprintf("%s_%d_%d.gz", time.Now().Format("2006-01-02_15-04-05"), os.Getpid(), spillCount))
+	spill, err := terminal.NewSpill(path)
+	if err != nil {
+		return err
+	}
+	s.spill = spill
+	return nil
+}
+
+// renderMain draws the visible part of the focused session's screen into the
+// main view, and the other pane's if the tab is split
+func (app *App) renderMain() error {
+	if err := app.renderSession(app.views.main, app.session, true); err != nil {
+		return err
+	}
+	return app.renderPane()
+}
+
+// renderSession draws the visible part of the session's screen into the view.
+// Only the focused session gets the cursor and the gutter.
+func (app *App) renderSession(v *gocui.View, s *session, focused bool) error {
+	screen := s.screen
+
+	_, height := v.Size()
+	total := screen.LineCount()
+	first := screen.FirstLine()
+	state := &s.mainView
+	state.scrollOffset = clampScrollOffset(state.scrollOffset, total-first, height)
+
+	end := total - state.scrollOffset
+	start := end - height
+	if start < first {
+		start = first
+	}
+
+	cursorX, _ := screen.Cursor()
+	cursorRow := screen.CursorLine() - start
+	cursorShown := screen.CursorVisible() && cursorRow >= 0 && cursorRow < end-start
+	if focused {
+		// the gui only has the one cursor, so we hide it when it would be in
+		// the wrong place
+		app.g.Cursor = app.g.CurrentView() != v || cursorShown
+	}
+
+	change := screen.Changes()
+	if state.rendered && change == state.renderedChange && state.scrollOffset == state.renderedOffset {
+		return nil
+	}
+	state.rendered = true
+	state.renderedChange = change
+	state.renderedOffset = state.scrollOffset
+
+	lines := screen.Lines(start, end)
+	if len(app.highlightRules) > 0 && !app.highlightsDisabled {
+		blocks := s.blocksSnapshot()
+		for i := range lines {
+			highlightLine(&lines[i], app.highlightRules, s.highlightCommand(blocks, total, start+i))
+		}
+	}
+
+	if focused {
+		app.renderGutter(lines)
+	}
+
+	v.Clear()
+	for i, line := range lines {
+		content := line.ANSI()
+		if content == "" {
+			continue
+		}
+		fmt.Fprintf(v, "\x1b[%d;1H%s", i+1, content)
+	}
+	if !focused {
+		return nil
+	}
+	if cursorShown {
+		// gocui expects the cursor to be on a line that's been written to,
+		// which it won't be if the rest of the screen is blank
+		fmt.Fprintf(v, "\x1b[%d;1H", cursorRow+1)
+	}
+
+	return v.SetCursor(cursorX, cursorRow)
+}
+
+func clampScrollOffset(offset int, total int, height int) int {
+	if offset > total-height {
+		offset = total - height
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
+
+func (app *App) scrollMainUp() error {
+	if forwarded, err := app.forwardMouse(mouseWheelUp); forwarded {
+		return err
+	}
+
+	_, height := app.views.main.Size()
+	screen := app.session.screen
+	state := &app.session.mainView
+	state.scrollOffset = clampScrollOffset(state.scrollOffset+1, screen.LineCount()-screen.FirstLine(), height)
+	return nil
+}
+
+func (app *App) scrollMainDown() error {
+	if forwarded, err := app.forwardMouse(mouseWheelDown); forwarded {
+		return err
+	}
+
+	if app.session.mainView.scrollOffset > 0 {
+		app.session.mainView.scrollOffset--
+	}
+	return nil
+}
+
+// resizeSession keeps the session's terminal the given size. When we're
+// replaying a recording the screen is the size of the recorded terminal.
+func (app *App) resizeSession(s *session, width int, height int) error {
+	if app.player != nil || s.screen == nil {
+		return nil
+	}
+	if currentWidth, currentHeight := s.screen.Size(); currentWidth == width && currentHeight == height {
+		return nil
+	}
+	s.screen.Resize(width, height)
+
+	if s.ptmx == nil || s.exited {
+		return nil
+	}
+	if err := app.resizePty(s.ptmx, s); err != nil {
+		return err
+	}
+	if s.recorder != nil {
+		return s.recorder.Resize(width, height)
+	}
+	return nil
+}
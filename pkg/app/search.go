@@ -0,0 +1,176 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// openCopyModeSearch prompts for something to search the scrollback for, like
+// '/' and '?' in less
+func (app *App) openCopyModeSearch(backward bool) func() error {
+	return func() error {
+		if app.copyMode == nil {
+			return nil
+		}
+		app.copyMode.searching = true
+		app.copyMode.searchBackward = backward
+
+		if err := app.layoutSearchView(app.g); err != nil {
+			return err
+		}
+		_, err := app.g.SetCurrentView("search")
+		return err
+	}
+}
+
+func (app *App) closeSearch() error {
+	if app.copyMode == nil || !app.copyMode.searching {
+		return nil
+	}
+	app.copyMode.searching = false
+	app.views.search = nil
+	if err := app.g.DeleteView("search"); err != nil {
+		return err
+	}
+	_, err := app.g.SetCurrentView("copy")
+	return err
+}
+
+func (app *App) confirmSearch() error {
+	query := ""
+	if app.views.search != nil {
+		query = strings.TrimSpace(app.views.search.Buffer())
+	}
+	if err := app.closeSearch(); err != nil {
+		return err
+	}
+	if query == "" {
+		return nil
+	}
+
+	app.copyMode.searchQuery = query
+	return app.searchNext(false)
+}
+
+// layoutSearchView puts the search prompt along the bottom of the copy view
+func (app *App) layoutSearchView(g *gocui.Gui) error {
+	if app.copyMode == nil || !app.copyMode.searching {
+		return nil
+	}
+
+	x0, _, x1, y1, err := g.ViewPosition("copy")
+	if err != nil {
+		return err
+	}
+
+	v, err := g.SetView("search", x0+1, y1-3, x1-1, y1-1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = true
+		v.Editable = true
+		app.views.search = v
+	}
+	v.Title = app.Tr.SearchTitle
+	if app.copyMode.searchBackward {
+		v.Title = app.Tr.SearchBackwardTitle
+	}
+
+	_, err = g.SetViewOnTop("search")
+	return err
+}
+
+// searchNext moves the copy mode cursor to the next match for the last
+// search. reverse flips the direction, for 'N'.
+func (app *App) searchNext(reverse bool) error {
+	cm := app.copyMode
+	if cm == nil || cm.searchQuery == "" {
+		return nil
+	}
+
+	backward := cm.searchBackward != reverse
+	x, y, found := cm.find(cm.searchQuery, backward)
+	if !found {
+		app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.SearchNotFound, cm.searchQuery), color.FgRed))
+		return nil
+	}
+
+	// a match inside a folded block needs the block unfolded to be seen
+	for i := range cm.blocks {
+		block := &cm.blocks[i]
+		if block.folded && y >= block.outputLine && y < block.end(len(cm.lines)) && y != block.promptLine {
+			block.folded = false
+			app.session.setBlockFolded(i, false)
+		}
+	}
+	cm.refreshRows()
+
+	return app.withCopyMode(func(cm *copyModeState) {
+		cm.x, cm.y = x, y
+		cm.clampX()
+	})
+}
+
+// find returns where the next match for query is, searching from just after
+// (or before) the cursor and wrapping around. Matching ignores case unless the
+// query has upper case letters in it, like vim's smartcase.
+func (cm *copyModeState) find(query string, backward bool) (int, int, bool) {
+	ignoreCase := strings.ToLower(query) == query
+	needle := []rune(query)
+	if ignoreCase {
+		needle = []rune(strings.ToLower(query))
+	}
+
+	matches := func(y int) []int {
+		line := cm.lines[y]
+		if ignoreCase {
+			line = []rune(strings.ToLower(string(line)))
+		}
+		return runeIndices(line, needle)
+	}
+
+	for i := 0; i <= len(cm.lines); i++ {
+		y := cm.y + i
+		if backward {
+			y = cm.y - i
+		}
+		y = (y%len(cm.lines) + len(cm.lines)) % len(cm.lines)
+
+		indices := matches(y)
+		if backward {
+			for j := len(indices) - 1; j >= 0; j-- {
+				if i > 0 || indices[j] < cm.x {
+					return indices[j], y, true
+				}
+			}
+			continue
+		}
+		for _, index := range indices {
+			if i > 0 || index > cm.x {
+				return index, y, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// runeIndices returns where needle starts within haystack, for every time it
+// appears
+func runeIndices(haystack []rune, needle []rune) []int {
+	indices := []int{}
+	if len(needle) == 0 {
+		return indices
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
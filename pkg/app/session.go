@@ -0,0 +1,250 @@
+package app
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jesseduffield/lazysession/pkg/asciicast"
+	"github.com/jesseduffield/lazysession/pkg/terminal"
+)
+
+// session is a wrapped command running in a pane of a tab, along with its terminal and
+// everything we know about what it has printed
+type session struct {
+	// name is what the tab is called
+	name string
+	// cmd is nil for a session replaying a recording, which only has the
+	// recordedCommand, if the recording says what it was
+	cmd             *exec.Cmd
+	recordedCommand string
+	ptmx            *os.File
+	// stdin is where what the user types goes, once the command has started
+	stdin io.Writer
+
+	exited     bool
+	exitStatus exitStatus
+	// done is closed once the current run of the command has exited
+	done chan struct{}
+	// closed is set when the tab is closed, after which we leave the command
+	// dead
+	closed bool
+	// commandStarted is when the current run of the command started
+	commandStarted time.Time
+	// restartTimer is running while we wait to restart the command, which
+	// we'll do at restartAt
+	restartTimer *time.Timer
+	restartAt    time.Time
+	// backoff is the last delay we waited before restarting with the
+	// 'backoff' policy
+	backoff time.Duration
+
+	// screen is what the command sees as its terminal. The main view shows a
+	// window onto it while the session's tab is selected.
+	screen   *terminal.Screen
+	mainView mainViewState
+	// spill is where lines go when they fall out of the scrollback, if the
+	// user wants to keep them
+	spill *terminal.Spill
+
+	blocks          []*outputBlock
+	blocksMutex     sync.Mutex
+	semanticPrompts bool
+	promptLine      int
+	commandX        int
+	commandY        int
+
+	// outputMutex guards transcript and lastOutput, which are written to as
+	// output comes in from the pty
+	outputMutex sync.Mutex
+	transcript  *transcript
+	recorder    *asciicast.Recorder
+	lastOutput  time.Time
+
+	notifications notifications
+	// lastSubmission is when we last sent the buffer to the command, which
+	// relative timestamps are measured from
+	lastSubmission time.Time
+
+	// historyNamespace picks which of the saved histories the buffer steps
+	// through. Sessions running the same program share one.
+	historyNamespace string
+	historyIndex     int
+	currentLine      string
+
+	// profile is what the session was started from, if it was started from a
+	// profile
+	profile *profile
+	// promptPattern matches the program's prompt, for programs that don't
+	// mark their prompts with OSC 133
+	promptPattern *regexp.Regexp
+	// lineTerminator is sent after the buffer when it's submitted
+	lineTerminator string
+	theme          guiTheme
+
+	// cwd is the command's working directory, as far as we know. cwdMutex
+	// guards it because it's updated as output comes in from the pty.
+	cwd      string
+	cwdMutex sync.Mutex
+}
+
+// defaultLineTerminator is what pressing enter sends
+const defaultLineTerminator = "\r"
+
+// newSession gets a command ready to run in a tab of its own. The screen is
+// created once we know how big the main view is.
+func (app *App) newSession(cmd *exec.Cmd) (*session, error) {
+	name := filepath.Base(cmd.Args[0])
+	s := &session{
+		name:             name,
+		cmd:              cmd,
+		historyNamespace: name,
+		historyIndex:     -1,
+		lineTerminator:   defaultLineTerminator,
+	}
+
+	theme, err := parseTheme(app.config.UserConfig.Gui.Theme)
+	if err != nil {
+		return nil, err
+	}
+	s.theme = theme
+
+	notifiers, err := compileNotifications(app.config.UserConfig.Notifications)
+	if err != nil {
+		return nil, err
+	}
+	s.notifications.notifiers = notifiers
+	s.notifications.setCommand(s.commandLine())
+
+	if app.config.UserConfig.Scrollback.Spill {
+		if err := app.startSpill(s); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// stopSignals are sent one after the other to a command we're stopping, until
+// it exits. SIGHUP is what it would get if its terminal were closed.
+var stopSignals = []syscall.Signal{syscall.SIGHUP, syscall.SIGTERM, syscall.SIGKILL}
+
+// stopTimeout is how long we give the command to exit after each signal
+const stopTimeout = 2 * time.Second
+
+// closeSession stops the session's command and cleans up after it. The
+// returned channel is closed once the command has exited, or we've given up
+// waiting for it to.
+func (app *App) closeSession(s *session) <-chan struct{} {
+	s.closed = true
+	if s.restartTimer != nil {
+		s.restartTimer.Stop()
+		s.restartTimer = nil
+	}
+	stopped := make(chan struct{})
+	go func() {
+		app.stopCommand(s)
+		close(stopped)
+	}()
+	if err := app.stopTranscript(s); err != nil {
+		app.Log.Error(err)
+	}
+	s.outputMutex.Lock()
+	if s.recorder != nil {
+		if err := s.recorder.Close(); err != nil {
+			app.Log.Error(err)
+		}
+		s.recorder = nil
+	}
+	s.outputMutex.Unlock()
+	if s.spill != nil {
+		if err := s.spill.Close(); err != nil {
+			app.Log.Error(err)
+		}
+	}
+	return stopped
+}
+
+// stopCommand signals the command's process group until it exits
+func (app *App) stopCommand(s *session) {
+	if s.done == nil || s.cmd.Process == nil {
+		return
+	}
+	select {
+	case <-s.done:
+		// its process group may not be its any more
+		return
+	default:
+	}
+
+	for i, signal := range stopSignals {
+		// the command leads a process group of its own, which its children
+		// are in unless they're jobs of a shell
+		_ = syscall.Kill(-s.cmd.Process.Pid, signal)
+		if i == 0 && s.ptmx != nil {
+			// hanging up the terminal gets SIGHUP to whatever job is in the
+			// foreground too
+			_ = s.ptmx.Close()
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(stopTimeout):
+		}
+	}
+	app.Log.Errorf("gave up waiting for %s to exit", s.name)
+}
+
+// showSession points the main view and the buffer at the current session,
+// e.g. after switching tabs or when its command starts or stops
+func (app *App) showSession() error {
+	s := app.session
+	if main := app.views.main; main != nil {
+		main.Pty = s.stdin != nil && !s.exited
+		main.StdinWriter = s.stdin
+	}
+	if app.views.buffer != nil {
+		// doing this so that if the buffer is focused we can press 'q' to exit
+		app.views.buffer.Editable = !s.exited
+	}
+	s.mainView.rendered = false
+	app.applyTheme(s.theme)
+	app.renderDefaultInfo()
+	app.requestRender()
+
+	if s.screen == nil {
+		return nil
+	}
+	return app.setFullScreen(s.screen.AltScreen())
+}
+
+// history returns the buffer history for the current session
+func (app *App) history() []string {
+	return app.state.Histories[app.session.historyNamespace]
+}
+
+func (app *App) addToHistory(entry string) {
+	history := app.history()
+	if len(history) > 0 && history[len(history)-1] == entry {
+		return
+	}
+	if app.state.Histories == nil {
+		app.state.Histories = map[string][]string{}
+	}
+	app.state.Histories[app.session.historyNamespace] = append(history, entry)
+}
+
+// commandLine is the session's command as it would be typed
+func (s *session) commandLine() string {
+	if s.cmd == nil {
+		return s.recordedCommand
+	}
+	return strings.Join(s.cmd.Args, " ")
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// statusLineInterval is how often we redraw the status line, for the clock
+// and anything else that changes without us hearing about it
+const statusLineInterval = time.Second
+
+// busyWindow is how recently the command must have printed something for us
+// to call it busy
+const busyWindow = time.Second
+
+// statusSegments fill in the segments of the status line. An empty string
+// leaves the segment out.
+var statusSegments = map[string]func(app *App) string{
+	"command":  (*App).commandSegment,
+	"name":     (*App).nameSegment,
+	"view":     (*App).viewSegment,
+	"mode":     (*App).modeSegment,
+	"history":  (*App).historySegment,
+	"activity": (*App).activitySegment,
+	"exitcode": (*App).exitCodeSegment,
+	"cwd":      (*App).cwdSegment,
+	"clock":    (*App).clockSegment,
+	"cpu":      (*App).cpuSegment,
+}
+
+var statusSegmentRegexp = regexp.MustCompile(`\{(\w+)\}`)
+
+// statusPart is a segment of the status line along with the text before it,
+// which is only shown along with the segment. Text after the last segment is
+// a part with no segment.
+type statusPart struct {
+	prefix  string
+	segment string
+}
+
+// parseStatusLine splits the template from the config into its parts
+func parseStatusLine(template string) ([]statusPart, error) {
+	parts := []statusPart{}
+	start := 0
+	for _, match := range statusSegmentRegexp.FindAllStringSubmatchIndex(template, -1) {
+		segment := template[match[2]:match[3]]
+		if _, ok := statusSegments[segment]; !ok {
+			return nil, fmt.Errorf("unknown status line segment '%s'", segment)
+		}
+		parts = append(parts, statusPart{prefix: template[start:match[0]], segment: segment})
+		start = match[1]
+	}
+	if start < len(template) {
+		parts = append(parts, statusPart{prefix: template[start:]})
+	}
+	return parts, nil
+}
+
+// renderStatusLine fills in the status line's segments for the focused
+// session, leaving out the last ones if there isn't room for them
+func (app *App) renderStatusLine() {
+	type filledPart struct {
+		prefix  string
+		segment string
+		value   string
+	}
+	parts := []filledPart{}
+	for _, part := range app.statusLine {
+		value := ""
+		if part.segment != "" {
+			if value = statusSegments[part.segment](app); value == "" {
+				continue
+			}
+		}
+		parts = append(parts, filledPart{prefix: part.prefix, segment: part.segment, value: value})
+	}
+	if len(parts) > 0 {
+		parts[0].prefix = strings.TrimLeft(parts[0].prefix, " ")
+	}
+
+	// the stats for the command sit at the right of the info view
+	width, _ := app.views.info.Size()
+	if app.processStats != "" {
+		width -= len(app.processStats) + 1
+	}
+	for len(parts) > 0 {
+		total := 0
+		for _, part := range parts {
+			total += runewidth.StringWidth(part.prefix + part.value)
+		}
+		if total <= width {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	theme := app.session.theme
+	line := ""
+	for _, part := range parts {
+		line += theme.optionsText.Sprint(part.prefix)
+		if c, ok := theme.statusColors[part.segment]; ok {
+			line += c.Sprint(part.value)
+		} else {
+			line += part.value
+		}
+	}
+	app.renderInfo(line)
+}
+
+// refreshStatusLine redraws the status line if that's what the info view is
+// showing, rather than some message
+func (app *App) refreshStatusLine() {
+	if app.statusShown {
+		app.renderDefaultInfo()
+	}
+}
+
+// startStatusLine keeps the status line up to date, if there is one, from
+// now on
+func (app *App) startStatusLine() {
+	if len(app.statusLine) > 0 && !app.statusLineTicking {
+		app.statusLineTicking = true
+		go app.tickStatusLine()
+	}
+}
+
+func (app *App) tickStatusLine() {
+	ticker := time.NewTicker(statusLineInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		app.update(func() error {
+			app.refreshStatusLine()
+			return nil
+		})
+	}
+}
+
+func (app *App) commandSegment() string {
+	s := app.session
+	if s.profile != nil {
+		return s.profile.commandLine()
+	}
+	return s.commandLine()
+}
+
+func (app *App) nameSegment() string {
+	if s := app.session; s.profile != nil {
+		return s.profile.name
+	}
+	return app.session.name
+}
+
+func (app *App) viewSegment() string {
+	if v := app.g.CurrentView(); v != nil {
+		return v.Name()
+	}
+	return ""
+}
+
+func (app *App) modeSegment() string {
+	switch {
+	case app.player != nil:
+		return app.Tr.ModePlayback
+	case app.copyMode != nil && app.copyMode.searching:
+		return app.Tr.ModeSearch
+	case app.copyMode != nil:
+		return app.Tr.ModeCopy
+	case app.fullScreen:
+		return app.Tr.ModeFullScreen
+	}
+
+	switch v := app.g.CurrentView(); v {
+	case app.views.buffer:
+		if v.Overwrite {
+			return app.Tr.ModeOverwrite
+		}
+		return app.Tr.ModeInsert
+	case app.views.main:
+		return app.Tr.ModeProgram
+	}
+	return ""
+}
+
+// historySegment is where we are in the buffer's history, like '12/340', or
+// '-/340' when we're not stepping through it
+func (app *App) historySegment() string {
+	history := app.history()
+	if app.session.historyIndex == -1 {
+		return fmt.Sprintf("-/%d", len(history))
+	}
+	return fmt.Sprintf("%d/%d", app.session.historyIndex+1, len(history))
+}
+
+func (app *App) activitySegment() string {
+	s := app.session
+	if s.stdin == nil || s.exited {
+		return ""
+	}
+	s.outputMutex.Lock()
+	lastOutput := s.lastOutput
+	s.outputMutex.Unlock()
+	if time.Since(lastOutput) < busyWindow {
+		return app.Tr.StatusBusy
+	}
+	return app.Tr.StatusIdle
+}
+
+// exitCodeSegment is how the command exited, or else how the last command run
+// at its prompt exited, if it told us with an OSC 133 mark
+func (app *App) exitCodeSegment() string {
+	s := app.session
+	if s.exited {
+		return fmt.Sprint(s.exitStatus.exitCode())
+	}
+
+	s.blocksMutex.Lock()
+	defer s.blocksMutex.Unlock()
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if s.blocks[i].endLine != -1 {
+			return s.blocks[i].exitCode
+		}
+	}
+	return ""
+}
+
+func (app *App) cwdSegment() string {
+	cwd := app.session.getCwd()
+	if home, err := os.UserHomeDir(); err == nil && cwd != "" {
+		if cwd == home {
+			return "~"
+		}
+		if strings.HasPrefix(cwd, home+string(filepath.Separator)) {
+			return "~" + cwd[len(home):]
+		}
+	}
+	return cwd
+}
+
+func (app *App) clockSegment() string {
+	return time.Now().Format("15:04")
+}
+
+func (app *App) cpuSegment() string {
+	pid, ok := app.commandPid()
+	if !ok {
+		return ""
+	}
+	if _, ok := app.cpuPercents[pid]; !ok {
+		return ""
+	}
+	return app.formatCPU(pid)
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatusLine(t *testing.T) {
+	type scenario struct {
+		name          string
+		template      string
+		expected      []statusPart
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"nothing", "", []statusPart{}, ""},
+		{"only text", "hello", []statusPart{{prefix: "hello"}}, ""},
+		{
+			"segments with text before and after",
+			"{name} | {cwd} ({clock})",
+			[]statusPart{
+				{prefix: "", segment: "name"},
+				{prefix: " | ", segment: "cwd"},
+				{prefix: " (", segment: "clock"},
+				{prefix: ")"},
+			},
+			"",
+		},
+		{
+			"segments next to each other",
+			"{mode}{exitcode}",
+			[]statusPart{{segment: "mode"}, {segment: "exitcode"}},
+			"",
+		},
+		{"braces that aren't a segment are text", "{ } {}", []statusPart{{prefix: "{ } {}"}}, ""},
+		{"an unknown segment", "{name} {branch}", nil, "unknown status line segment 'branch'"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			parts, err := parseStatusLine(s.template)
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, s.expected, parts)
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+// tab shows one session, or two side by side when it's split
+type tab struct {
+	panes []*session
+	// focused is the index of the pane that gets what the user types
+	focused int
+	// vertical puts the panes side by side rather than one above the other
+	vertical bool
+	// share is the percentage of the tab that the first pane takes up
+	share int
+	// draft is what was in the buffer when we last switched away from the tab
+	draft string
+}
+
+func newTab(s *session) *tab {
+	return &tab{panes: []*session{s}, share: defaultPaneShare}
+}
+
+func (t *tab) split() bool {
+	return len(t.panes) > 1
+}
+
+// sessions returns the session in every pane of every tab
+func (app *App) sessions() []*session {
+	sessions := []*session{}
+	for _, t := range app.tabs {
+		sessions = append(sessions, t.panes...)
+	}
+	return sessions
+}
+
+// tabsShown says whether the tab bar takes up a row above the main view. With
+// only one unsplit tab there's nothing to tell apart, so we leave it out.
+func (app *App) tabsShown() bool {
+	return (len(app.tabs) > 1 || app.tab.split()) && !app.fullScreen
+}
+
+func (app *App) layoutTabs(g *gocui.Gui) error {
+	if !app.tabsShown() {
+		if app.views.tabs != nil {
+			if err := g.DeleteView("tabs"); err != nil {
+				return err
+			}
+			app.views.tabs = nil
+		}
+		return nil
+	}
+
+	width, _ := g.Size()
+	v, err := g.SetView("tabs", -1, -1, width, 1, 0)
+	if err != nil {
+		if err.Error() != "unknown view" {
+			return err
+		}
+		v.Frame = false
+		v.Wrap = false
+		app.views.tabs = v
+	}
+	app.renderTabs()
+	return nil
+}
+
+// renderTabs draws the tab bar, with the selected tab in reverse video, the
+// focused pane of a split tab underlined, and a '*' after any pane whose
+// command has exited
+func (app *App) renderTabs() {
+	v := app.views.tabs
+	if v == nil {
+		return
+	}
+
+	labels := make([]string, len(app.tabs))
+	for i, t := range app.tabs {
+		names := make([]string, len(t.panes))
+		for j, s := range t.panes {
+			names[j] = s.name
+			if s.exited {
+				names[j] += "*"
+			}
+			if t.split() && j == t.focused {
+				names[j] = "\x1b[4m" + names[j] + "\x1b[24m"
+			}
+		}
+		label := fmt.Sprintf(" %d:%s ", i+1, strings.Join(names, "|"))
+		if t == app.tab {
+			label = "\x1b[7m" + label + "\x1b[0m"
+		}
+		labels[i] = label
+	}
+
+	v.Clear()
+	fmt.Fprint(v, strings.Join(labels, "│"))
+}
+
+// startSession makes a session for the command the user typed into a prompt
+// and starts it running with a screen of the given size. Leaving the prompt
+// empty starts the user's shell.
+func (app *App) startSession(command string, width int, height int) (*session, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "sh"
+		}
+		args = []string{shell}
+	}
+	// a misspelt command is better caught here than once the session's open
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, err
+	}
+
+	s, err := app.newSession(exec.Command(args[0], args[1:]...))
+	if err != nil {
+		return nil, err
+	}
+	if app.config.UserConfig.Transcript.Enabled {
+		if err := app.startTranscript(s, ""); err != nil {
+			app.closeSession(s)
+			return nil, err
+		}
+	}
+	s.screen = app.newScreen(s, width, height)
+
+	app.startCommand(s)
+	return s, nil
+}
+
+// openNewTabPrompt asks for a command to run in a new tab
+func (app *App) openNewTabPrompt() error {
+	if app.player != nil {
+		return nil
+	}
+	return app.createPrompt(app.Tr.NewTabTitle, "", app.openTab)
+}
+
+func (app *App) openTab(command string) error {
+	width, height := app.paneArea.size()
+	s, err := app.startSession(command, width, height)
+	if err != nil {
+		return app.renderError(err)
+	}
+
+	t := newTab(s)
+	app.tabs = append(app.tabs, t)
+	return app.switchToTab(t)
+}
+
+// closePane stops the command in the focused pane. The tab goes with it unless
+// it's split, and closing the last tab quits.
+func (app *App) closePane() error {
+	if app.player != nil {
+		return nil
+	}
+
+	t := app.tab
+	if t.split() {
+		s := app.session
+		t.panes = append(t.panes[:t.focused], t.panes[t.focused+1:]...)
+		app.closeSession(s)
+		return app.focusPane(0)
+	}
+
+	if len(app.tabs) == 1 {
+		return app.quit()
+	}
+
+	index := app.tabIndex(t)
+	app.tabs = append(app.tabs[:index], app.tabs[index+1:]...)
+	for _, s := range t.panes {
+		app.closeSession(s)
+	}
+
+	if index == len(app.tabs) {
+		index--
+	}
+	return app.switchToTab(app.tabs[index])
+}
+
+func (app *App) openRenameTabPrompt() error {
+	return app.createPrompt(app.Tr.RenameTabTitle, app.session.name, app.renameTab)
+}
+
+// renameTab renames the focused pane, which is what names an unsplit tab
+func (app *App) renameTab(name string) error {
+	if name == "" {
+		return nil
+	}
+	app.session.name = name
+	return nil
+}
+
+func (app *App) nextTab() error {
+	index := (app.tabIndex(app.tab) + 1) % len(app.tabs)
+	return app.switchToTab(app.tabs[index])
+}
+
+func (app *App) prevTab() error {
+	index := (app.tabIndex(app.tab) - 1 + len(app.tabs)) % len(app.tabs)
+	return app.switchToTab(app.tabs[index])
+}
+
+func (app *App) tabIndex(t *tab) int {
+	for i, other := range app.tabs {
+		if other == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// switchToTab selects the tab, keeping whatever was typed into the buffer for
+// the tab we're leaving
+func (app *App) switchToTab(t *tab) error {
+	if err := app.exitCopyMode(); err != nil {
+		return err
+	}
+
+	if prev := app.tab; prev != t {
+		// a tab that's just been closed takes its draft with it
+		if app.tabIndex(prev) != -1 {
+			prev.draft = app.views.buffer.Buffer()
+		}
+		app.views.buffer.Clear()
+		fmt.Fprint(app.views.buffer, t.draft)
+	}
+	app.tab = t
+	app.session = t.panes[t.focused]
+	for _, s := range t.panes {
+		s.mainView.rendered = false
+	}
+
+	if err := app.showSession(); err != nil {
+		return err
+	}
+	if len(app.tabs) > 1 {
+		app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.SwitchedTab, app.tabIndex(t)+1, app.session.name,
+			app.keyHint("global", "newTab"), app.keyHint("global", "prevTab", "nextTab"), app.keyHint("global", "renameTab"),
+			app.keyHint("global", "closePane"), app.keyHint("global", "prefixMenu")), color.FgGreen))
+	}
+	return nil
+}
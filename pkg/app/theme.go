@@ -0,0 +1,121 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/gocui"
+	"github.com/jesseduffield/lazysession/pkg/config"
+)
+
+// guiTheme is a config.ThemeConfig ready to be applied
+type guiTheme struct {
+	activeBorder gocui.Attribute
+	optionsText  *color.Color
+	// statusColors are for the status line's segments, by name
+	statusColors map[string]*color.Color
+}
+
+var themeColors = map[string]struct {
+	gocui gocui.Attribute
+	text  color.Attribute
+}{
+	"default": {gocui.ColorDefault, color.Reset},
+	"black":   {gocui.ColorBlack, color.FgBlack},
+	"red":     {gocui.ColorRed, color.FgRed},
+	"green":   {gocui.ColorGreen, color.FgGreen},
+	"yellow":  {gocui.ColorYellow, color.FgYellow},
+	"blue":    {gocui.ColorBlue, color.FgBlue},
+	"magenta": {gocui.ColorMagenta, color.FgMagenta},
+	"cyan":    {gocui.ColorCyan, color.FgCyan},
+	"white":   {gocui.ColorWhite, color.FgWhite},
+}
+
+var themeAttributes = map[string]struct {
+	gocui gocui.Attribute
+	text  color.Attribute
+}{
+	"bold":      {gocui.AttrBold, color.Bold},
+	"underline": {gocui.AttrUnderline, color.Underline},
+	"reverse":   {gocui.AttrReverse, color.ReverseVideo},
+}
+
+// parseTheme checks the colours in the theme. Each is a list of colour names
+// and attributes, e.g. [green, bold], with later colours winning.
+func parseTheme(theme config.ThemeConfig) (guiTheme, error) {
+	activeBorder, _, err := parseThemeColor(theme.ActiveBorderColor)
+	if err != nil {
+		return guiTheme{}, fmt.Errorf("active border colour: %v", err)
+	}
+	_, optionsText, err := parseThemeColor(theme.OptionsTextColor)
+	if err != nil {
+		return guiTheme{}, fmt.Errorf("options text colour: %v", err)
+	}
+	if _, _, err := parseThemeColor(theme.InactiveBorderColor); err != nil {
+		return guiTheme{}, fmt.Errorf("inactive border colour: %v", err)
+	}
+	statusColors := map[string]*color.Color{}
+	for segment, keys := range theme.StatusLineColors {
+		if _, ok := statusSegments[segment]; !ok {
+			return guiTheme{}, fmt.Errorf("status line colours: unknown segment '%s'", segment)
+		}
+		_, text, err := parseThemeColor(keys)
+		if err != nil {
+			return guiTheme{}, fmt.Errorf("%s colour: %v", segment, err)
+		}
+		statusColors[segment] = text
+	}
+	return guiTheme{activeBorder: activeBorder, optionsText: optionsText, statusColors: statusColors}, nil
+}
+
+// parseThemeColor returns the colour for gocui to draw with and for us to
+// colour text with
+func parseThemeColor(keys []string) (gocui.Attribute, *color.Color, error) {
+	attribute := gocui.ColorDefault
+	text := color.New()
+	for _, key := range keys {
+		if c, ok := themeColors[key]; ok {
+			// the colour takes up the bits below the attributes
+			attribute = attribute&^0x1ff | c.gocui
+			text.Add(c.text)
+		} else if a, ok := themeAttributes[key]; ok {
+			attribute |= a.gocui
+			text.Add(a.text)
+		} else {
+			return 0, nil, fmt.Errorf("unknown colour '%s'", key)
+		}
+	}
+	return attribute, text, nil
+}
+
+// mergeThemes returns the base theme with any colours the override sets
+func mergeThemes(base config.ThemeConfig, override config.ThemeConfig) config.ThemeConfig {
+	if len(override.ActiveBorderColor) > 0 {
+		base.ActiveBorderColor = override.ActiveBorderColor
+	}
+	if len(override.InactiveBorderColor) > 0 {
+		base.InactiveBorderColor = override.InactiveBorderColor
+	}
+	if len(override.OptionsTextColor) > 0 {
+		base.OptionsTextColor = override.OptionsTextColor
+	}
+	if len(override.StatusLineColors) > 0 {
+		colors := map[string][]string{}
+		for segment, keys := range base.StatusLineColors {
+			colors[segment] = keys
+		}
+		for segment, keys := range override.StatusLineColors {
+			colors[segment] = keys
+		}
+		base.StatusLineColors = colors
+	}
+	return base
+}
+
+// applyTheme draws the frame of the focused view, e.g. the buffer, in the
+// theme's active border colour
+func (app *App) applyTheme(theme guiTheme) {
+	app.g.Highlight = true
+	app.g.SelFgColor = theme.activeBorder
+	app.g.SelBgColor = gocui.ColorDefault
+}
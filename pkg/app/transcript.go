@@ -0,0 +1,144 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jesseduffield/lazysession/pkg/utils"
+)
+
+const transcriptsDirname = "transcripts"
+
+// transcript logs everything the wrapped program prints to a file, in the
+// same format as script(1) so that it can be replayed with `cat` or `less -R`.
+// With timestamps, each line starts with the time its first output arrived.
+type transcript struct {
+	file       *os.File
+	path       string
+	timestamps bool
+	// midLine is true when the last thing we wrote wasn't a newline
+	midLine bool
+}
+
+func openTranscript(path string, command []string, timestamps bool) (*transcript, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf(
+		"Script started on %s [COMMAND=%q TERM=%q]\n",
+		time.Now().Format("2006-01-02 15:04:05-07:00"),
+		strings.Join(command, " "),
+		os.Getenv("TERM"),
+	)
+	if _, err := file.WriteString(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &transcript{file: file, path: path, timestamps: timestamps}, nil
+}
+
+func (t *transcript) Write(p []byte) (int, error) {
+	if !t.timestamps || len(p) == 0 {
+		return t.file.Write(p)
+	}
+
+	stamp := []byte("[" + time.Now().Format(exportTimeFormat) + "] ")
+	out := make([]byte, 0, len(p)+len(stamp))
+	for _, b := range p {
+		if !t.midLine {
+			out = append(out, stamp...)
+			t.midLine = true
+		}
+		out = append(out, b)
+		if b == '\n' {
+			t.midLine = false
+		}
+	}
+	if _, err := t.file.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *transcript) Close() error {
+	footer := fmt.Sprintf("\nScript done on %s\n", time.Now().Format("2006-01-02 15:04:05-07:00"))
+	if _, err := t.file.WriteString(footer); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+// defaultTranscriptPath names the transcript after the session too, so that
+// tabs opened in the same second don't share one
+func (app *App) defaultTranscriptPath(s *session) string {
+	filename := time.Now().Format("2006-01-02_15-04-05") + "_" + filepath.Base(s.cmd.Args[0]) + ".log"
+	return filepath.Join(app.config.ConfigDir, transcriptsDirname, filename)
+}
+
+// startTranscript begins logging the session's output to the given path, or
+// to a new file in the config directory if the path is empty
+func (app *App) startTranscript(s *session, path string) error {
+	if path == "" {
+		path = app.defaultTranscriptPath(s)
+	}
+
+	t, err := openTranscript(path, s.cmd.Args, app.config.UserConfig.Transcript.Timestamps)
+	if err != nil {
+		return err
+	}
+
+	s.outputMutex.Lock()
+	defer s.outputMutex.Unlock()
+	s.transcript = t
+	return nil
+}
+
+func (app *App) stopTranscript(s *session) error {
+	s.outputMutex.Lock()
+	defer s.outputMutex.Unlock()
+
+	if s.transcript == nil {
+		return nil
+	}
+	err := s.transcript.Close()
+	s.transcript = nil
+	return err
+}
+
+// toggleTranscript starts or stops logging the current session's output
+func (app *App) toggleTranscript() error {
+	// a recording being replayed isn't a command's output
+	if app.player != nil {
+		return nil
+	}
+	s := app.session
+	s.outputMutex.Lock()
+	t := s.transcript
+	s.outputMutex.Unlock()
+
+	if t != nil {
+		if err := app.stopTranscript(s); err != nil {
+			return app.renderError(err)
+		}
+		app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.TranscriptStopped, t.path), color.FgGreen))
+		return nil
+	}
+
+	if err := app.startTranscript(s, ""); err != nil {
+		return app.renderError(err)
+	}
+	app.renderInfo(utils.ColoredString(fmt.Sprintf(app.Tr.TranscriptStarted, s.transcript.path), color.FgGreen))
+	return nil
+}
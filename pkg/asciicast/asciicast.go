@@ -0,0 +1,259 @@
+// Package asciicast reads and writes recordings in the asciinema v2 format.
+// See https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event types
+const (
+	Output = "o"
+	Input  = "i"
+	Resize = "r"
+)
+
+// Header is the first line of a recording
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is a single line of a recording after the header
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+// MarshalJSON writes the event as a [time, type, data] tuple
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+// UnmarshalJSON reads the event from a [time, type, data] tuple
+func (e *Event) UnmarshalJSON(content []byte) error {
+	tuple := []interface{}{}
+	if err := json.Unmarshal(content, &tuple); err != nil {
+		return err
+	}
+	if len(tuple) != 3 {
+		return fmt.Errorf("expected event to have 3 elements, got %d", len(tuple))
+	}
+
+	var ok bool
+	if e.Time, ok = tuple[0].(float64); !ok {
+		return errors.New("expected event time to be a number")
+	}
+	if e.Type, ok = tuple[1].(string); !ok {
+		return errors.New("expected event type to be a string")
+	}
+	if e.Data, ok = tuple[2].(string); !ok {
+		return errors.New("expected event data to be a string")
+	}
+	return nil
+}
+
+// Recorder writes a recording as the session happens. Nothing is written
+// until we know the terminal size, which comes with the first call to Resize.
+type Recorder struct {
+	file    *os.File
+	encoder *json.Encoder
+	header  Header
+	start   time.Time
+	mutex   sync.Mutex
+
+	headerWritten bool
+	pending       []Event
+
+	// output and input can be split mid-rune across writes, so we hold onto
+	// incomplete runes until the rest arrives
+	partial map[string][]byte
+}
+
+// NewRecorder creates the recording file
+func NewRecorder(path string, command string, env map[string]string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{
+		file:    file,
+		encoder: json.NewEncoder(file),
+		header: Header{
+			Version: 2,
+			Command: command,
+			Env:     env,
+		},
+		start:   time.Now(),
+		partial: map[string][]byte{},
+	}, nil
+}
+
+// Resize records the terminal's size. The first call writes the header.
+func (r *Recorder) Resize(width, height int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.headerWritten {
+		return r.writeEvent(Resize, fmt.Sprintf("%dx%d", width, height))
+	}
+
+	r.header.Width = width
+	r.header.Height = height
+	r.header.Timestamp = r.start.Unix()
+	if err := r.encoder.Encode(r.header); err != nil {
+		return err
+	}
+	r.headerWritten = true
+
+	for _, event := range r.pending {
+		if err := r.encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	r.pending = nil
+	return nil
+}
+
+// Output records bytes written by the program
+func (r *Recorder) Output(p []byte) error {
+	return r.record(Output, p)
+}
+
+// Input records bytes sent to the program
+func (r *Recorder) Input(p []byte) error {
+	return r.record(Input, p)
+}
+
+func (r *Recorder) record(eventType string, p []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	content := append(r.partial[eventType], p...)
+	complete := len(content)
+	// step back over an incomplete rune at the end, if there is one
+	for i := len(content) - 1; i >= 0 && i >= len(content)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(content[i]) {
+			if !utf8.FullRune(content[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	r.partial[eventType] = append([]byte{}, content[complete:]...)
+
+	if complete == 0 {
+		return nil
+	}
+	return r.writeEvent(eventType, string(content[:complete]))
+}
+
+func (r *Recorder) writeEvent(eventType string, data string) error {
+	event := Event{
+		Time: time.Since(r.start).Seconds(),
+		Type: eventType,
+		Data: data,
+	}
+	if !r.headerWritten {
+		r.pending = append(r.pending, event)
+		return nil
+	}
+	return r.encoder.Encode(event)
+}
+
+// Close closes the recording file
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.headerWritten {
+		r.header.Width, r.header.Height = 80, 24
+		if err := r.encoder.Encode(r.header); err != nil {
+			r.file.Close()
+			return err
+		}
+		for _, event := range r.pending {
+			if err := r.encoder.Encode(event); err != nil {
+				r.file.Close()
+				return err
+			}
+		}
+		r.headerWritten = true
+	}
+
+	return r.file.Close()
+}
+
+// Recording is a recording that has been read from disk
+type Recording struct {
+	Header Header
+	Events []Event
+}
+
+// Duration is the time of the last event
+func (r *Recording) Duration() float64 {
+	if len(r.Events) == 0 {
+		return 0
+	}
+	return r.Events[len(r.Events)-1].Time
+}
+
+// Load reads a recording from disk
+func Load(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Read parses a recording
+func Read(reader io.Reader) (*Recording, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("recording is empty")
+	}
+
+	recording := &Recording{}
+	if err := json.Unmarshal(scanner.Bytes(), &recording.Header); err != nil {
+		return nil, fmt.Errorf("could not parse recording header: %s", err)
+	}
+	if recording.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d", recording.Header.Version)
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("could not parse line %d of recording: %s", line, err)
+		}
+		recording.Events = append(recording.Events, event)
+	}
+
+	return recording, scanner.Err()
+}
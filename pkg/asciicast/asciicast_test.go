@@ -0,0 +1,162 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventJSON(t *testing.T) {
+	type scenario struct {
+		name          string
+		json          string
+		event         Event
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{"output", `[0.5,"o","hi\r\n"]`, Event{Time: 0.5, Type: Output, Data: "hi\r\n"}, ""},
+		{"escape sequences", `[1,"o","\u001b[31m"]`, Event{Time: 1, Type: Output, Data: "\x1b[31m"}, ""},
+		{"a resize", `[2.25,"r","100x30"]`, Event{Time: 2.25, Type: Resize, Data: "100x30"}, ""},
+		{"not a tuple", `{"time":1}`, Event{}, "json: cannot unmarshal object into Go value of type []interface {}"},
+		{"too short", `[1,"o"]`, Event{}, "expected event to have 3 elements, got 2"},
+		{"a time that isn't a number", `["1","o","x"]`, Event{}, "expected event time to be a number"},
+		{"a type that isn't a string", `[1,1,"x"]`, Event{}, "expected event type to be a string"},
+		{"data that isn't a string", `[1,"o",null]`, Event{}, "expected event data to be a string"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			event := Event{}
+			err := json.Unmarshal([]byte(s.json), &event)
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, s.event, event)
+
+			content, err := json.Marshal(event)
+			assert.NoError(t, err)
+			assert.JSONEq(t, s.json, string(content))
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	type scenario struct {
+		name          string
+		content       string
+		expected      *Recording
+		expectedError string
+	}
+
+	scenarios := []scenario{
+		{
+			"a header and events, with a blank line",
+			`{"version":2,"width":80,"height":24,"command":"vim"}` + "\n" +
+				`[0.1,"o","a"]` + "\n\n" +
+				`[0.2,"i","b"]` + "\n",
+			&Recording{
+				Header: Header{Version: 2, Width: 80, Height: 24, Command: "vim"},
+				Events: []Event{{Time: 0.1, Type: Output, Data: "a"}, {Time: 0.2, Type: Input, Data: "b"}},
+			},
+			"",
+		},
+		{
+			"only a header",
+			`{"version":2,"width":10,"height":5}`,
+			&Recording{Header: Header{Version: 2, Width: 10, Height: 5}},
+			"",
+		},
+		{"nothing", "", nil, "recording is empty"},
+		{"a bad header", "not json\n", nil, "could not parse recording header: invalid character 'o' in literal null (expecting 'u')"},
+		{"version 1", `{"version":1,"width":80,"height":24}`, nil, "unsupported asciicast version 1"},
+		{
+			"a bad event",
+			`{"version":2,"width":80,"height":24}` + "\n" + `[0.1,"o","a"]` + "\n" + `[0.2,"o"]`,
+			nil,
+			"could not parse line 3 of recording: expected event to have 3 elements, got 2",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			recording, err := Read(strings.NewReader(s.content))
+			if s.expectedError != "" {
+				assert.EqualError(t, err, s.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, s.expected, recording)
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asciicast")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.cast")
+
+	recorder, err := NewRecorder(path, "bash -l", map[string]string{"TERM": "xterm-256color"})
+	assert.NoError(t, err)
+	// output before we know the size waits for the header
+	assert.NoError(t, recorder.Output([]byte("early ")))
+	assert.NoError(t, recorder.Resize(100, 30))
+	// a rune split across writes is held back until it's whole
+	assert.NoError(t, recorder.Output([]byte("caf\xc3")))
+	assert.NoError(t, recorder.Output([]byte("\xa9")))
+	assert.NoError(t, recorder.Input([]byte("q")))
+	assert.NoError(t, recorder.Resize(80, 24))
+	assert.NoError(t, recorder.Close())
+
+	recording, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, recording.Header.Version)
+	assert.Equal(t, 100, recording.Header.Width)
+	assert.Equal(t, 30, recording.Header.Height)
+	assert.Equal(t, "bash -l", recording.Header.Command)
+	assert.Equal(t, map[string]string{"TERM": "xterm-256color"}, recording.Header.Env)
+	assert.NotZero(t, recording.Header.Timestamp)
+
+	types := []string{}
+	data := []string{}
+	for i, event := range recording.Events {
+		types = append(types, event.Type)
+		data = append(data, event.Data)
+		if i > 0 {
+			assert.True(t, event.Time >= recording.Events[i-1].Time)
+		}
+	}
+	assert.Equal(t, []string{Output, Output, Output, Input, Resize}, types)
+	assert.Equal(t, []string{"early ", "caf", "é", "q", "80x24"}, data)
+	assert.Equal(t, recording.Events[4].Time, recording.Duration())
+}
+
+// a session that never learned its size still makes a recording that can be
+// played back
+func TestRecorderWithoutResize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asciicast")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.cast")
+
+	recorder, err := NewRecorder(path, "ls", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, recorder.Output([]byte("a b c\r\n")))
+	assert.NoError(t, recorder.Close())
+
+	recording, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 80, recording.Header.Width)
+	assert.Equal(t, 24, recording.Header.Height)
+	assert.Len(t, recording.Events, 1)
+	assert.Equal(t, "a b c\r\n", recording.Events[0].Data)
+	assert.Equal(t, float64(0), (&Recording{}).Duration())
+}
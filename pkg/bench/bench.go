@@ -0,0 +1,173 @@
+// Package bench measures how lazysession copes with a program that prints as
+// fast as it can, and how much CPU it uses while nothing is happening. It runs
+// a lazysession binary in a pty of its own, the way a user's terminal would.
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/jesseduffield/pty"
+)
+
+// the flood command prints this, split in two so that it only appears once
+// the command has actually run
+const doneMarker = "lazysession-bench-done"
+
+// Options says what to measure
+type Options struct {
+	// Binary is the lazysession binary to run
+	Binary string
+	// Bytes is how much output the flood command prints
+	Bytes int
+	// Idle is how long to leave lazysession idling for
+	Idle time.Duration
+	// Width and Height are the size of the pty
+	Width, Height int
+}
+
+// Result holds the measurements
+type Result struct {
+	Bytes         int
+	FloodDuration time.Duration
+	FloodCPU      time.Duration
+	IdleDuration  time.Duration
+	IdleCPU       time.Duration
+}
+
+// Throughput is how many bytes per second got through to the screen
+func (r Result) Throughput() float64 {
+	return float64(r.Bytes) / r.FloodDuration.Seconds()
+}
+
+// IdleCPUPercent is the CPU used while idle, as a percentage of one core. It
+// includes starting up, so it errs on the high side.
+func (r Result) IdleCPUPercent() float64 {
+	return 100 * r.IdleCPU.Seconds() / r.IdleDuration.Seconds()
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf(
+		"flood: %d bytes in %s (%.1f MB/s), %s CPU\nidle:  %s CPU over %s (%.2f%% of a core)",
+		r.Bytes,
+		r.FloodDuration.Round(time.Millisecond),
+		r.Throughput()/1e6,
+		r.FloodCPU.Round(time.Millisecond),
+		r.IdleCPU.Round(time.Millisecond),
+		r.IdleDuration.Round(time.Millisecond),
+		r.IdleCPUPercent(),
+	)
+}
+
+// command runs the given command in lazysession. We keep the session in
+// lazysession's own process so that it's the process we measure, and so that
+// nothing's left running in the background when we kill it.
+func command(options Options, args ...string) *exec.Cmd {
+	return exec.Command(options.Binary, append([]string{"--no-server"}, args...)...)
+}
+
+// Run takes the measurements
+func Run(options Options) (Result, error) {
+	result := Result{Bytes: options.Bytes}
+
+	script := fmt.Sprintf("yes | head -c %d; printf '%%s-%%s\\n' %s %s", options.Bytes, doneMarker[:11], doneMarker[12:])
+	if err := runFlood(options, script, &result); err != nil {
+		return result, err
+	}
+
+	if err := runIdle(options, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// runFlood times how long it takes for all of the output to be drawn. We
+// know it's been drawn when the marker printed after it shows up on our side
+// of the pty.
+func runFlood(options Options, script string, result *Result) error {
+	cmd := command(options, "sh", "-c", script)
+	ptmx, err := start(cmd, options)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	started := time.Now()
+	if err := waitFor(ptmx, []byte(doneMarker)); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	result.FloodDuration = time.Since(started)
+
+	// the command has exited, so 'q' quits
+	go drain(ptmx)
+	if _, err := ptmx.Write([]byte("q")); err != nil {
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return err
+	}
+
+	result.FloodCPU = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	return nil
+}
+
+// runIdle measures the CPU used while the program is waiting around
+func runIdle(options Options, result *Result) error {
+	cmd := command(options, "sleep", strconv.Itoa(int(options.Idle.Seconds())+60))
+	ptmx, err := start(cmd, options)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+	go drain(ptmx)
+
+	started := time.Now()
+	time.Sleep(options.Idle)
+	if err := cmd.Process.Kill(); err != nil {
+		return err
+	}
+	// it's been killed, so the error just tells us that
+	_ = cmd.Wait()
+
+	result.IdleDuration = time.Since(started)
+	result.IdleCPU = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	return nil
+}
+
+func start(cmd *exec.Cmd, options Options) (*os.File, error) {
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	return pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(options.Width), Rows: uint16(options.Height)})
+}
+
+// waitFor reads from r until it has seen the given bytes
+func waitFor(r io.Reader, want []byte) error {
+	buf := make([]byte, 64*1024)
+	// enough of what we've read to catch the marker across reads
+	tail := []byte{}
+	for {
+		n, err := r.Read(buf)
+		tail = append(tail, buf[:n]...)
+		if bytes.Contains(tail, want) {
+			return nil
+		}
+		if len(tail) > len(want) {
+			tail = tail[len(tail)-len(want):]
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func drain(r io.Reader) {
+	_, _ = io.Copy(ioutil.Discard, r)
+}
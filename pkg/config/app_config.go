@@ -20,6 +20,23 @@ type AppConfig struct {
 	BuildSource string `long:"build-source" env:"BUILD_SOURCE" default:""`
 	UserConfig  *UserConfig
 	ConfigDir   string
+
+	// TranscriptPath is where to log the session's output to, if anywhere
+	TranscriptPath string `long:"transcript"`
+
+	// RecordPath is where to record the session to in asciicast format, if anywhere
+	RecordPath string `long:"record"`
+
+	// Profile is the name of the profile in the user config to run, if any
+	Profile string `long:"profile"`
+
+	// Env are KEY=VALUE pairs to add to the environment of every command we
+	// run, on top of what profiles add
+	Env []string `long:"env"`
+
+	// SocketPath is the socket of the server we're running under, if any. It's
+	// how we ask to be detached from the terminal.
+	SocketPath string
 }
 
 // NewAppConfig makes a new app config
@@ -59,6 +76,17 @@ func findOrCreateConfigDir(projectName string) (string, error) {
 	return folders[0].Path, nil
 }
 
+// UserConfigPath is where the user's config is read from
+func (c *AppConfig) UserConfigPath() string {
+	return filepath.Join(c.ConfigDir, "config.yml")
+}
+
+// ReloadUserConfig reads the user's config again, e.g. after it's been
+// edited. It's up to the caller to put it in place.
+func (c *AppConfig) ReloadUserConfig() (*UserConfig, error) {
+	return loadUserConfig(c.ConfigDir)
+}
+
 func loadUserConfig(configDir string) (*UserConfig, error) {
 	config := getDefaultConfig()
 
@@ -78,20 +106,247 @@ func loadUserConfig(configDir string) (*UserConfig, error) {
 
 // UserConfig is the user's config
 type UserConfig struct {
-	Gui       GuiConfig
-	Reporting string
+	Gui           GuiConfig
+	Clipboard     ClipboardConfig
+	Transcript    TranscriptConfig
+	Scrollback    ScrollbackConfig
+	Highlights    []HighlightRule
+	Notifications []NotificationConfig
+	Restart       RestartConfig
+	Profiles      map[string]ProfileConfig
+	Keybindings   map[string]map[string]KeyList
+	Reporting     string
 }
 
 // GuiConfig is the user's gui config
 type GuiConfig struct {
 	Theme ThemeConfig
+	// MaxFPS is the most times per second we redraw while the program is
+	// printing
+	MaxFPS int
+	// Timestamps is what the gutter next to the program's output starts off
+	// showing: 'off', 'absolute' for the time each line arrived, or 'relative'
+	// for how long after the last submission it arrived
+	Timestamps string
+	// StatusLine is what the info view shows while there's nothing else to
+	// say, in place of the hint about the tab key. Segments in braces are
+	// filled in, and any text before a segment goes with it, e.g.
+	//
+	//	statusLine: '{name} {mode} · history {history} · {activity} · exit {exitcode} · {cwd} · {clock}'
+	//
+	// The segments are command, name (of the profile or session), view (which
+	// is focused), mode, history (e.g. 12/340), activity (busy or idle),
+	// exitcode, cwd, clock and cpu. Segments with nothing to show are left out,
+	// as are the last ones if the line doesn't fit.
+	StatusLine string
 }
 
-// ThemeConfig is the user's config
+// ThemeConfig is the user's config. Each colour is a list of any of the
+// colours default, black, red, green, yellow, blue, magenta, cyan and white,
+// and the attributes bold, underline and reverse.
 type ThemeConfig struct {
-	ActiveBorderColor   []string
+	// ActiveBorderColor is for the frame of the focused view, e.g. the buffer
+	ActiveBorderColor []string
+	// InactiveBorderColor isn't used yet: gocui draws the frames of views that
+	// aren't focused in the same colour as their text
 	InactiveBorderColor []string
-	OptionsTextColor    []string
+	// OptionsTextColor is for the hint in the info view
+	OptionsTextColor []string
+	// StatusLineColors are for the segments of the status line, by name
+	StatusLineColors map[string][]string
+}
+
+// ClipboardConfig determines where text yanked in copy mode ends up
+type ClipboardConfig struct {
+	// Method is one of 'auto', 'osc52', 'command' or 'tmux'. With 'auto' we use
+	// tmux buffers when inside tmux, then Command if it's set, then OSC 52.
+	Method string
+	// Command is run through the shell with the yanked text on stdin, e.g.
+	// 'xclip -selection clipboard', 'wl-copy' or 'pbcopy'
+	Command string
+}
+
+// TranscriptConfig determines whether we log everything the wrapped program
+// prints, like script(1) does
+type TranscriptConfig struct {
+	// Enabled turns on transcript logging for every session. Transcripts are
+	// written to the 'transcripts' folder in the config directory.
+	Enabled bool
+	// Timestamps starts each line of the transcript with the time it arrived
+	Timestamps bool
+}
+
+// ScrollbackConfig determines how much of the wrapped program's output we
+// keep around to scroll back through
+type ScrollbackConfig struct {
+	// Lines is the most lines we keep in memory. 0, the default, means no
+	// limit.
+	Lines int
+	// Bytes is roughly the most memory we use for the scrollback. 0 means no
+	// limit.
+	Bytes int
+	// Spill keeps lines that no longer fit in a compressed file in the
+	// 'scrollback' folder of the config directory, so that they can still be
+	// searched and exported. The file is deleted when we exit.
+	Spill bool
+}
+
+// HighlightRule colours whatever matches Pattern in the main view, on top of
+// whatever colours the program used, e.g.
+//
+//	highlights:
+//	  - pattern: '\b(ERROR|FATAL)\b'
+//	    fg: red
+//	    attributes: [bold]
+//	  - pattern: 'ORA-\d{5}'
+//	    bg: '#5f0000'
+//	    command: sqlplus
+type HighlightRule struct {
+	// Pattern is a Go regular expression
+	Pattern string
+	// Fg and Bg are colour names like 'red' or 'brightblue', numbers in the
+	// 256 colour palette, or hex colours like '#ff8700'. Empty means leave the
+	// colour alone.
+	Fg string
+	Bg string
+	// Attributes are any of bold, dim, italic, underline, blink, reverse and
+	// strikethrough
+	Attributes []string
+	// Command is a regular expression that limits the rule to the output of
+	// commands that match it. The command is whatever was typed at the prompt
+	// when we know that, otherwise the command lazysession is running.
+	Command string
+}
+
+// NotificationConfig says what to do when something happens that the user
+// might want to know about while they're looking at another window, e.g.
+//
+//	notifications:
+//	  - trigger: idle
+//	    idleseconds: 30
+//	    actions: [flash, osc9]
+//	  - trigger: match
+//	    pattern: 'ERROR'
+//	    actions: [command]
+//	    command: 'notify-send "$LAZYSESSION_MESSAGE"'
+type NotificationConfig struct {
+	// Trigger is one of:
+	// match: a line of output matches Pattern
+	// idle: there's been no output for IdleSeconds since something was
+	// submitted from the buffer
+	// exit: the command exits
+	// bell: the command rings the bell
+	Trigger     string
+	Pattern     string
+	IdleSeconds int
+	// Actions are any of:
+	// flash: show the notification in the info view
+	// bell: ring the bell in the terminal lazysession is running in
+	// osc9, osc777: ask the terminal for a desktop notification, using the
+	// escape sequence it understands (iTerm2 and Windows Terminal use osc9,
+	// foot and urxvt use osc777)
+	// command: run Command with the shell. It gets LAZYSESSION_TRIGGER,
+	// LAZYSESSION_MESSAGE, LAZYSESSION_COMMAND, and depending on the trigger
+	// LAZYSESSION_MATCH, LAZYSESSION_EXIT_CODE or LAZYSESSION_IDLE_SECONDS in
+	// its environment.
+	Actions []string
+	Command string
+}
+
+// RestartConfig determines whether we start the wrapped program again when it
+// exits. It can always be restarted by hand with 'r'.
+type RestartConfig struct {
+	// Policy is one of 'never', 'always', 'on-failure' (a non-zero exit code
+	// or a signal) or 'backoff', which is like 'on-failure' but waits twice as
+	// long each time the program fails again soon after starting
+	Policy string
+	// DelaySeconds is how long we wait before restarting. With 'backoff' it's
+	// the first delay.
+	DelaySeconds int
+	// MaxDelaySeconds is the longest we wait with 'backoff'. A program that
+	// stays up for longer than this goes back to the first delay.
+	MaxDelaySeconds int
+}
+
+// ProfileConfig is a command that's run often enough to be worth naming,
+// along with how to run it. Profiles are run with 'lazysession -p <name>', or
+// picked from a list when lazysession is started without a command, e.g.
+//
+//	profiles:
+//	  prod-db:
+//	    command: psql
+//	    args: [-h, db.internal, -U, readonly, orders]
+//	    env:
+//	      PGCONNECT_TIMEOUT: '5'
+//	    unsetenv: [PGPASSWORD]
+//	    dir: ~/work/orders
+//	    promptpattern: '^orders=[>#] '
+//	    lineterminator: "\n"
+//	    theme:
+//	      activebordercolor: [red, bold]
+type ProfileConfig struct {
+	Command string
+	Args    []string
+	// Env is added to the environment the command inherits from us
+	Env map[string]string
+	// UnsetEnv are variables to take out of the environment the command
+	// inherits from us
+	UnsetEnv []string
+	// Dir is the directory to run the command in. It defaults to the one
+	// lazysession was started in.
+	Dir string
+	// HistoryNamespace picks the buffer history the profile uses. It defaults
+	// to the command's name, so that the profile shares its history with the
+	// command run by hand.
+	HistoryNamespace string
+	// PromptPattern is a Go regular expression matching the program's prompt.
+	// Programs that don't mark their prompts with OSC 133 can use it to tell us
+	// where each command's output ends.
+	PromptPattern string
+	// LineTerminator is what we send after the buffer when it's submitted. It
+	// defaults to a carriage return, which is what pressing enter sends.
+	LineTerminator string
+	// Theme overrides the gui theme while the profile's session is focused.
+	// Colours left out are taken from the gui theme.
+	Theme ThemeConfig
+}
+
+// KeyList is the keys bound to an action in the keybindings config, e.g.
+//
+//	keybindings:
+//	  buffer:
+//	    send: ['<c-l>', '<a-enter>']
+//	    copyMode: []
+//	  global:
+//	    quit: '<c-q>'
+//
+// The config is split into sections for the views the actions work in, plus
+// 'global' for those that work anywhere. Actions that aren't mentioned keep
+// their keys, and the help (F1, or ? anywhere but the program's view and a
+// buffer with something typed in it) lists every action along with its
+// section.
+// Keys are single characters, control keys like '<c-q>', names like '<f1>'
+// and '<esc>', or any of those with alt, like '<a-x>'. A single key can be
+// given on its own, and an empty list unbinds the action.
+type KeyList []string
+
+// UnmarshalYAML lets a single key stand in for a list of one
+func (k *KeyList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var key string
+	if err := unmarshal(&key); err == nil {
+		if key == "" {
+			*k = KeyList{}
+		} else {
+			*k = KeyList{key}
+		}
+		return nil
+	}
+	var keys []string
+	if err := unmarshal(&keys); err != nil {
+		return err
+	}
+	*k = keys
+	return nil
 }
 
 // getDefaultConfig returns the application default configuration
@@ -102,8 +357,44 @@ func getDefaultConfig() UserConfig {
 				ActiveBorderColor:   []string{"white", "bold"},
 				InactiveBorderColor: []string{"white", "blue"},
 				OptionsTextColor:    []string{"blue"},
+				StatusLineColors: map[string][]string{
+					"command":  {"cyan"},
+					"name":     {"green", "bold"},
+					"view":     {"blue"},
+					"mode":     {"yellow"},
+					"history":  {"magenta"},
+					"activity": {"yellow"},
+					"exitcode": {"red"},
+					"cwd":      {"blue"},
+					"clock":    {"default"},
+					"cpu":      {"cyan"},
+				},
 			},
+			MaxFPS:     60,
+			Timestamps: "off",
+		},
+		Clipboard: ClipboardConfig{
+			Method:  "auto",
+			Command: "",
+		},
+		Transcript: TranscriptConfig{
+			Enabled:    false,
+			Timestamps: false,
+		},
+		Scrollback: ScrollbackConfig{
+			Lines: 0,
+			Bytes: 0,
+			Spill: false,
+		},
+		Highlights:    []HighlightRule{},
+		Notifications: []NotificationConfig{},
+		Restart: RestartConfig{
+			Policy:          "never",
+			DelaySeconds:    1,
+			MaxDelaySeconds: 60,
 		},
-		Reporting: "undetermined",
+		Profiles:    map[string]ProfileConfig{},
+		Keybindings: map[string]map[string]KeyList{},
+		Reporting:   "undetermined",
 	}
 }
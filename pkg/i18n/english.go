@@ -2,13 +2,271 @@ package i18n
 
 // TranslationSet is a set of localised strings for a given language
 type TranslationSet struct {
-	AddFavourite string
-	ErrorMessage string
+	AddFavourite           string
+	ErrorMessage           string
+	CopyModeInfo           string
+	CopiedToClipboard      string
+	NoClipboardCommand     string
+	UnknownClipboardMethod string
+	TranscriptStarted      string
+	TranscriptStopped      string
+	ScrollbackExported     string
+	ExportScrollbackTitle  string
+	ExportPlain            string
+	ExportANSI             string
+	ExportHTML             string
+	Playing                string
+	Paused                 string
+	PlaybackKeys           string
+	SearchTitle            string
+	SearchBackwardTitle    string
+	SearchNotFound         string
+	HighlightsOn           string
+	HighlightsOff          string
+	NotifyMatch            string
+	NotifyIdle             string
+	NotifyExit             string
+	NotifyBell             string
+	TimestampsOff          string
+	TimestampsAbsolute     string
+	TimestampsRelative     string
+	ExportPlainTimestamps  string
+	CommandExited          string
+	CommandKilled          string
+	RestartingIn           string
+	RestartOrQuit          string
+	RestartSeparator       string
+	NewTabTitle            string
+	RenameTabTitle         string
+	SwitchedTab            string
+	PrefixMenuTitle        string
+	SplitPaneTitle         string
+	SplitSideBySide        string
+	SplitAboveBelow        string
+	FocusOtherPane         string
+	SwapPanes              string
+	GrowPane               string
+	ShrinkPane             string
+	FocusedPane            string
+	Detach                 string
+	PickProfileTitle       string
+	NestedWarning          string
+	SwitchViewHint         string
+	Unbound                string
+	ProcessStats           string
+	ShowProcesses          string
+	ProcessTreeTitle       string
+	SendSignalTitle        string
+	SentSignal             string
+	NotRunning             string
+	ModePlayback           string
+	ModeSearch             string
+	ModeCopy               string
+	ModeFullScreen         string
+	ModeOverwrite          string
+	ModeInsert             string
+	ModeProgram            string
+	StatusBusy             string
+	StatusIdle             string
+	ScrollDown             string
+	ScrollUp               string
+	SwitchView             string
+	SendBuffer             string
+	PrevHistoryItem        string
+	NextHistoryItem        string
+	EnterCopyMode          string
+	PasteYanked            string
+	ShowKeybindings        string
+	ExportScrollback       string
+	ToggleTranscript       string
+	ToggleHighlights       string
+	ToggleTimestamps       string
+	NewTab                 string
+	PrevTab                string
+	NextTab                string
+	RenameTab              string
+	ClosePane              string
+	ToggleFullScreen       string
+	OpenPrefixMenu         string
+	PrefixMenuShortcut     string
+	TogglePlaybackPause    string
+	PlaybackFaster         string
+	PlaybackSlower         string
+	SeekBackward           string
+	SeekForward            string
+	MenuUp                 string
+	MenuDown               string
+	MenuConfirm            string
+	MenuCancel             string
+	CopyModeUp             string
+	CopyModeDown           string
+	CopyModeLeft           string
+	CopyModeRight          string
+	CopyModeHalfPageUp     string
+	CopyModeHalfPageDown   string
+	CopyModeLineStart      string
+	CopyModeLineEnd        string
+	CopyModeTop            string
+	CopyModeBottom         string
+	SelectChars            string
+	SelectLines            string
+	SelectBlock            string
+	PrevCommandBlock       string
+	NextCommandBlock       string
+	ToggleFold             string
+	YankOutput             string
+	YankCommand            string
+	YankSelection          string
+	SearchForward          string
+	SearchBackward         string
+	NextMatch              string
+	PrevMatch              string
+	ExitCopyMode           string
+	ConfirmSearch          string
+	Confirm                string
+	Cancel                 string
+	SendControlKey         string
+	Quit                   string
+	QuitIfExited           string
+	RestartCommand         string
+	ConfigReloaded         string
+	ConfigNotReloaded      string
+	ConfigNeedsRestart     string
+	KeybindingsTitle       string
 }
 
 func englishSet() TranslationSet {
 	return TranslationSet{
-		AddFavourite: "Add favourite",
-		ErrorMessage: "Error Message",
+		AddFavourite:           "Add favourite",
+		ErrorMessage:           "Error Message",
+		CopyModeInfo:           "copy mode: %s to move, %s to select, %s to yank, %s to jump between commands, %s to fold, %s to yank output/command, %s to search, %s for all the keys, %s to leave",
+		CopiedToClipboard:      "copied %d characters to the clipboard",
+		NoClipboardCommand:     "clipboard method is 'command' but no clipboard command is configured",
+		UnknownClipboardMethod: "unknown clipboard method '%s'",
+		TranscriptStarted:      "logging transcript to %s",
+		TranscriptStopped:      "stopped logging transcript to %s",
+		ScrollbackExported:     "exported scrollback to %s",
+		ExportScrollbackTitle:  "Export scrollback",
+		ExportPlain:            "plain text",
+		ExportANSI:             "ANSI",
+		ExportHTML:             "HTML",
+		Playing:                "playing",
+		Paused:                 "paused",
+		PlaybackKeys:           "space: pause, +/-: speed, ←/→: seek, q: quit",
+		SearchTitle:            "Search",
+		SearchBackwardTitle:    "Search backward",
+		SearchNotFound:         "'%s' not found",
+		HighlightsOn:           "highlighting on",
+		HighlightsOff:          "highlighting off",
+		NotifyMatch:            "output matched: %s",
+		NotifyIdle:             "no output for %ds",
+		NotifyExit:             "command exited with code %d",
+		NotifyBell:             "the command rang the bell",
+		TimestampsOff:          "timestamps off",
+		TimestampsAbsolute:     "showing when each line arrived",
+		TimestampsRelative:     "showing when each line arrived relative to the last submission",
+		ExportPlainTimestamps:  "plain text with timestamps",
+		CommandExited:          "command exited with code %d",
+		CommandKilled:          "command killed by signal %d (%s)",
+		RestartingIn:           "restarting in %s, 'q' to quit",
+		RestartOrQuit:          "%s to restart, %s to quit",
+		RestartSeparator:       "restarted at %s",
+		NewTabTitle:            "Command to run in the new tab (empty for a shell)",
+		RenameTabTitle:         "Rename tab",
+		SwitchedTab:            "tab %d: %s (%s: new, %s: switch, %s: rename, %s: close, %s: panes)",
+		PrefixMenuTitle:        "Panes and session",
+		SplitPaneTitle:         "Command to run in the new pane (empty for a shell)",
+		SplitSideBySide:        "split side by side",
+		SplitAboveBelow:        "split one above the other",
+		FocusOtherPane:         "focus the other pane",
+		SwapPanes:              "swap panes",
+		GrowPane:               "grow the focused pane",
+		ShrinkPane:             "shrink the focused pane",
+		FocusedPane:            "focused %s (%s o: other pane, %s: close pane)",
+		Detach:                 "detach, leaving the session running",
+		PickProfileTitle:       "Run a profile",
+		NestedWarning:          "warning: running inside another lazysession, which gets %s first",
+		SwitchViewHint:         "use %s to switch from the program to the buffer, %s for all the keys",
+		Unbound:                "(unbound)",
+		ProcessStats:           "pid %d  up %s  cpu %s  mem %s",
+		ShowProcesses:          "show the command's processes, to send them signals",
+		ProcessTreeTitle:       "Processes: pid, state, cpu, memory, command",
+		SendSignalTitle:        "Send a signal to %d",
+		SentSignal:             "sent %s to %d",
+		NotRunning:             "the command isn't running",
+		ModePlayback:           "playback",
+		ModeSearch:             "search",
+		ModeCopy:               "copy",
+		ModeFullScreen:         "full screen",
+		ModeOverwrite:          "overwrite",
+		ModeInsert:             "insert",
+		ModeProgram:            "program",
+		StatusBusy:             "busy",
+		StatusIdle:             "idle",
+		ScrollDown:             "scroll down",
+		ScrollUp:               "scroll up",
+		SwitchView:             "switch between the program and the buffer",
+		SendBuffer:             "send the buffer to the program",
+		PrevHistoryItem:        "previous command in the history",
+		NextHistoryItem:        "next command in the history",
+		EnterCopyMode:          "enter copy mode",
+		PasteYanked:            "paste what was last yanked",
+		ShowKeybindings:        "show the keybindings",
+		ExportScrollback:       "export the scrollback",
+		ToggleTranscript:       "start or stop the transcript",
+		ToggleHighlights:       "turn highlights on or off",
+		ToggleTimestamps:       "cycle the timestamps in the gutter",
+		NewTab:                 "open a new tab",
+		PrevTab:                "previous tab",
+		NextTab:                "next tab",
+		RenameTab:              "rename the tab",
+		ClosePane:              "close the focused pane",
+		ToggleFullScreen:       "toggle full screen",
+		OpenPrefixMenu:         "open the panes and session menu",
+		PrefixMenuShortcut:     "%s, while the panes and session menu is open",
+		TogglePlaybackPause:    "pause or resume",
+		PlaybackFaster:         "play faster",
+		PlaybackSlower:         "play slower",
+		SeekBackward:           "skip back",
+		SeekForward:            "skip forward",
+		MenuUp:                 "move up",
+		MenuDown:               "move down",
+		MenuConfirm:            "pick the selected item",
+		MenuCancel:             "close the menu",
+		CopyModeUp:             "move up",
+		CopyModeDown:           "move down",
+		CopyModeLeft:           "move left",
+		CopyModeRight:          "move right",
+		CopyModeHalfPageUp:     "half a page up",
+		CopyModeHalfPageDown:   "half a page down",
+		CopyModeLineStart:      "start of the line",
+		CopyModeLineEnd:        "end of the line",
+		CopyModeTop:            "top of the scrollback",
+		CopyModeBottom:         "bottom of the scrollback",
+		SelectChars:            "select characters",
+		SelectLines:            "select lines",
+		SelectBlock:            "select a rectangle",
+		PrevCommandBlock:       "previous command's output",
+		NextCommandBlock:       "next command's output",
+		ToggleFold:             "fold or unfold the command's output",
+		YankOutput:             "yank the command's output",
+		YankCommand:            "yank the command",
+		YankSelection:          "yank the selection",
+		SearchForward:          "search forward",
+		SearchBackward:         "search backward",
+		NextMatch:              "next match",
+		PrevMatch:              "previous match",
+		ExitCopyMode:           "leave copy mode",
+		ConfirmSearch:          "search",
+		Confirm:                "confirm",
+		Cancel:                 "cancel",
+		SendControlKey:         "send %s to the program",
+		Quit:                   "quit",
+		QuitIfExited:           "quit, once the command has exited",
+		RestartCommand:         "restart the command, once it has exited",
+		ConfigReloaded:         "reloaded the config",
+		ConfigNotReloaded:      "kept the last good config, %s has a problem: %s",
+		ConfigNeedsRestart:     "reloaded the config, but changes to %s need a restart",
+		KeybindingsTitle:       "Keybindings",
 	}
 }
@@ -0,0 +1,169 @@
+// Package procs reads what the kernel knows about running processes from
+// /proc, which is enough to tell whether a program is busy or stuck and what
+// it's running. Where there's no /proc, e.g. on macOS, there's nothing to read.
+package procs
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is how many ticks a second the CPU times in /proc are counted
+// in. It's USER_HZ, which is 100 everywhere Linux runs these days.
+const clockTicks = 100
+
+// Process is a snapshot of a running process
+type Process struct {
+	Pid  int
+	PPid int
+	// Command is the process's command line, or its name in brackets if it
+	// doesn't have one, like ps shows kernel threads
+	Command string
+	// State is R for running, S for sleeping, D for waiting on a disk, T for
+	// stopped, Z for a zombie, and so on
+	State string
+	// CPUTime is how much user and system time the process has used
+	CPUTime time.Duration
+	// RSS is how much of its memory is resident, in bytes
+	RSS int64
+}
+
+// Supported says whether there's a /proc to read
+func Supported() bool {
+	_, err := os.Stat("/proc/self/stat")
+	return err == nil
+}
+
+// Read takes a snapshot of the process
+func Read(pid int) (Process, error) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return Process{}, err
+	}
+	p, err := parseStat(string(stat))
+	if err != nil {
+		return Process{}, fmt.Errorf("/proc/%d/stat: %v", pid, err)
+	}
+
+	// the command line can be gone by the time we read it, which just means
+	// we keep the name from stat
+	if cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		if args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"); args[0] != "" {
+			p.Command = strings.Join(args, " ")
+		}
+	}
+	return p, nil
+}
+
+// parseStat parses the contents of /proc/<pid>/stat, except for the command
+// line, which it leaves as the name in brackets
+func parseStat(stat string) (Process, error) {
+	// the name is in parentheses and can have anything in it, parentheses and
+	// spaces included, so we go by the last closing one
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start == -1 || end < start {
+		return Process{}, errors.New("no process name")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stat[:start]))
+	if err != nil {
+		return Process{}, err
+	}
+
+	// fields[0] is the state, which is field 3 in proc(5)
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return Process{}, errors.New("too few fields")
+	}
+	field := func(n int) int64 {
+		value, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return value
+	}
+
+	ticks := field(14) + field(15)
+	return Process{
+		Pid:     pid,
+		PPid:    int(field(4)),
+		Command: "[" + stat[start+1:end] + "]",
+		State:   fields[0],
+		CPUTime: time.Duration(ticks) * time.Second / clockTicks,
+		RSS:     field(24) * int64(os.Getpagesize()),
+	}, nil
+}
+
+// List takes a snapshot of every process we can see
+func List() ([]Process, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := []Process{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// processes come and go while we're looking
+		if p, err := Read(pid); err == nil {
+			processes = append(processes, p)
+		}
+	}
+	return processes, nil
+}
+
+// TreeEntry is a process along with how far below the root of a tree it is
+type TreeEntry struct {
+	Process
+	Depth int
+}
+
+// Tree picks the root process and its descendants out of the processes,
+// parents before their children and siblings in order of pid
+func Tree(root int, processes []Process) []TreeEntry {
+	children := map[int][]Process{}
+	var rootProcess *Process
+	for i, p := range processes {
+		children[p.PPid] = append(children[p.PPid], p)
+		if p.Pid == root {
+			rootProcess = &processes[i]
+		}
+	}
+	if rootProcess == nil {
+		return nil
+	}
+	for _, siblings := range children {
+		sort.Slice(siblings, func(i, j int) bool { return siblings[i].Pid < siblings[j].Pid })
+	}
+
+	tree := []TreeEntry{}
+	var walk func(p Process, depth int)
+	walk = func(p Process, depth int) {
+		tree = append(tree, TreeEntry{Process: p, Depth: depth})
+		for _, child := range children[p.Pid] {
+			walk(child, depth+1)
+		}
+	}
+	walk(*rootProcess, 0)
+	return tree
+}
+
+// FormatMemory formats a number of bytes like '45.2M'
+func FormatMemory(bytes int64) string {
+	units := []string{"K", "M", "G", "T"}
+	value := float64(bytes) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
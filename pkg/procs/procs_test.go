@@ -0,0 +1,71 @@
+package procs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStat(t *testing.T) {
+	type scenario struct {
+		name     string
+		stat     string
+		expected Process
+	}
+
+	pageSize := int64(os.Getpagesize())
+	scenarios := []scenario{
+		{
+			"a shell",
+			"4242 (bash) S 4241 4242 4242 34816 4300 4194304 1200 3400 0 0 150 50 2 1 20 0 1 0 98765 12345678 900 18446744073709551615\n",
+			Process{Pid: 4242, PPid: 4241, Command: "[bash]", State: "S", CPUTime: 2 * time.Second, RSS: 900 * pageSize},
+		},
+		{
+			"a name with spaces and parentheses in it",
+			"77 (tmux: server (1)) R 1 77 77 0 -1 4194560 500 0 0 0 3 4 0 0 20 0 1 0 555 1000 10 18446744073709551615\n",
+			Process{Pid: 77, PPid: 1, Command: "[tmux: server (1)]", State: "R", CPUTime: 70 * time.Millisecond, RSS: 10 * pageSize},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			p, err := parseStat(s.stat)
+			assert.NoError(t, err)
+			assert.Equal(t, s.expected, p)
+		})
+	}
+
+	_, err := parseStat("12 (cut short) S 1 2 3")
+	assert.Error(t, err)
+}
+
+func TestTree(t *testing.T) {
+	processes := []Process{
+		{Pid: 1, PPid: 0},
+		{Pid: 10, PPid: 1},
+		{Pid: 30, PPid: 10},
+		{Pid: 20, PPid: 10},
+		{Pid: 21, PPid: 20},
+		{Pid: 40, PPid: 1},
+	}
+
+	tree := Tree(10, processes)
+	pids := []int{}
+	depths := []int{}
+	for _, entry := range tree {
+		pids = append(pids, entry.Pid)
+		depths = append(depths, entry.Depth)
+	}
+	assert.Equal(t, []int{10, 20, 21, 30}, pids)
+	assert.Equal(t, []int{0, 1, 2, 1}, depths)
+
+	assert.Nil(t, Tree(99, processes))
+}
+
+func TestFormatMemory(t *testing.T) {
+	assert.Equal(t, "512.0K", FormatMemory(512*1024))
+	assert.Equal(t, "45.2M", FormatMemory(45*1024*1024+210*1024))
+	assert.Equal(t, "2.0G", FormatMemory(2*1024*1024*1024))
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// what the TUI sets up when it starts, which a terminal attaching later
+// needs doing again: the alternate screen, application keypad mode and mouse
+// reporting
+const (
+	enterTerminalModes = "\x1b[?1049h\x1b[?1h\x1b=\x1b[?1000h\x1b[?1002h\x1b[?1015h\x1b[?1006h"
+	exitTerminalModes  = "\x1b[?1000l\x1b[?1002l\x1b[?1015l\x1b[?1006l\x1b[?1l\x1b>\x1b[?25h\x1b[0m\x1b[?1049l"
+)
+
+// Attach connects the terminal we're running in to the server at the socket
+// path until the user detaches or lazysession exits, returning which of the
+// two it was
+func Attach(socketPath string) (string, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	fd := int(os.Stdin.Fd())
+	cols, rows, err := terminal.GetSize(fd)
+	if err != nil {
+		return "", err
+	}
+	if err := writeMessage(conn, msgAttach, formatSize(cols, rows)); err != nil {
+		return "", err
+	}
+
+	oldState, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = terminal.Restore(fd, oldState) }()
+	os.Stdout.WriteString(enterTerminalModes)
+
+	resizes := make(chan os.Signal, 1)
+	signal.Notify(resizes, syscall.SIGWINCH)
+	defer signal.Stop(resizes)
+	go func() {
+		for range resizes {
+			if cols, rows, err := terminal.GetSize(fd); err == nil {
+				_ = writeMessage(conn, msgResize, formatSize(cols, rows))
+			}
+		}
+	}()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				if err := writeMessage(conn, msgInput, buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			// the server went away without saying why, so we tidy up after it
+			os.Stdout.WriteString(exitTerminalModes)
+			return ReasonExited, nil
+		}
+		switch msg.kind {
+		case msgOutput:
+			os.Stdout.Write(msg.payload)
+		case msgClose:
+			reason := string(msg.payload)
+			if reason != ReasonExited {
+				// lazysession puts the terminal back the way it was when it
+				// exits, but not when we leave it running
+				os.Stdout.WriteString(exitTerminalModes)
+			}
+			return reason, nil
+		}
+	}
+}
+
+// Detach asks the server at the socket path to let go of its client
+func Detach(socketPath string) error {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return writeMessage(conn, msgDetach, nil)
+}
+
+// List returns the names of the running sessions, tidying away the sockets of
+// any that died without cleaning up after themselves
+func List(configDir string) ([]string, error) {
+	dir := filepath.Join(configDir, socketsDirname)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := []string{}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".sock") {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		if !alive(path) {
+			_ = os.Remove(path)
+			continue
+		}
+		names = append(names, strings.TrimSuffix(file.Name(), ".sock"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// alive says whether there's a server listening on the socket path
+func alive(socketPath string) bool {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	configDir, err := ioutil.TempDir("", "sessions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(configDir)
+
+	names, err := List(configDir)
+	assert.NoError(t, err)
+	assert.Empty(t, names, "there's no sessions folder until a server starts")
+
+	dir := filepath.Join(configDir, socketsDirname)
+	assert.NoError(t, os.MkdirAll(dir, 0700))
+	for _, name := range []string{"vim", "bash-2"} {
+		listener, err := net.Listen("unix", SocketPath(configDir, name))
+		assert.NoError(t, err)
+		defer listener.Close()
+	}
+	// a server that crashed leaves its socket behind
+	assert.NoError(t, ioutil.WriteFile(SocketPath(configDir, "dead"), nil, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600))
+
+	names, err = List(configDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bash-2", "vim"}, names)
+
+	_, err = os.Stat(SocketPath(configDir, "dead"))
+	assert.True(t, os.IsNotExist(err), "the dead socket is tidied away")
+	_, err = os.Stat(filepath.Join(dir, "notes.txt"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// messages that go over the socket. Each is a type byte, a big-endian uint32
+// length and then that many bytes of payload.
+const (
+	// msgAttach is the first thing a client sends, with its terminal's size as
+	// '<cols> <rows>'
+	msgAttach byte = 'a'
+	// msgInput is what the user typed
+	msgInput byte = 'i'
+	// msgResize says the client's terminal is now '<cols> <rows>'
+	msgResize byte = 'r'
+	// msgDetach asks the server to let go of whichever client is attached
+	msgDetach byte = 'd'
+	// msgOutput is what lazysession printed
+	msgOutput byte = 'o'
+	// msgClose is the last thing the server sends a client, saying why
+	msgClose byte = 'c'
+)
+
+// why the server let go of a client
+const (
+	// ReasonDetached means the user detached, or attached from somewhere else
+	ReasonDetached = "detached"
+	// ReasonExited means lazysession has quit, so there's nothing to come back to
+	ReasonExited = "exited"
+)
+
+// maxPayload stops a garbled message from making us allocate all the memory
+// in the world
+const maxPayload = 1 << 20
+
+type message struct {
+	kind    byte
+	payload []byte
+}
+
+func writeMessage(w io.Writer, kind byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	// one write per message so that messages from different goroutines don't
+	// get interleaved
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func readMessage(r io.Reader) (message, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return message{}, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxPayload {
+		return message{}, fmt.Errorf("message of %d bytes is too big", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return message{}, err
+	}
+	return message{kind: header[0], payload: payload}, nil
+}
+
+func formatSize(cols int, rows int) []byte {
+	return []byte(fmt.Sprintf("%d %d", cols, rows))
+}
+
+func parseSize(payload []byte) (int, int, error) {
+	var cols, rows int
+	if _, err := fmt.Sscanf(string(payload), "%d %d", &cols, &rows); err != nil {
+		return 0, 0, fmt.Errorf("bad terminal size '%s': %v", payload, err)
+	}
+	return cols, rows, nil
+}
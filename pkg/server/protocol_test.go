@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessages(t *testing.T) {
+	type scenario struct {
+		name    string
+		kind    byte
+		payload []byte
+		encoded []byte
+	}
+
+	scenarios := []scenario{
+		{"no payload", msgDetach, nil, []byte("d\x00\x00\x00\x00")},
+		{"a size", msgAttach, formatSize(80, 24), []byte("a\x00\x00\x00\x0580 24")},
+		{"raw input", msgInput, []byte("\x1b[A\x00"), []byte("i\x00\x00\x00\x04\x1b[A\x00")},
+		{"a reason", msgClose, []byte(ReasonExited), []byte("c\x00\x00\x00\x06exited")},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			assert.NoError(t, writeMessage(buf, s.kind, s.payload))
+			assert.Equal(t, s.encoded, buf.Bytes())
+
+			msg, err := readMessage(buf)
+			assert.NoError(t, err)
+			assert.Equal(t, s.kind, msg.kind)
+			assert.Equal(t, len(s.payload), len(msg.payload))
+			assert.Equal(t, string(s.payload), string(msg.payload))
+		})
+	}
+}
+
+func TestReadMessageStream(t *testing.T) {
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeMessage(buf, msgOutput, []byte("one")))
+	assert.NoError(t, writeMessage(buf, msgOutput, []byte("two")))
+
+	for _, expected := range []string{"one", "two"} {
+		msg, err := readMessage(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(msg.payload))
+	}
+	_, err := readMessage(buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	type scenario struct {
+		name     string
+		input    []byte
+		expected string
+	}
+
+	scenarios := []scenario{
+		{"a header cut short", []byte("o\x00\x00"), "unexpected EOF"},
+		{"a payload cut short", []byte("o\x00\x00\x00\x05abc"), "unexpected EOF"},
+		{"a payload that's too big", []byte("o\x00\x10\x00\x01"), "message of 1048577 bytes is too big"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			_, err := readMessage(bytes.NewReader(s.input))
+			assert.EqualError(t, err, s.expected)
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	type scenario struct {
+		name        string
+		payload     string
+		cols, rows  int
+		expectError bool
+	}
+
+	scenarios := []scenario{
+		{"what formatSize makes", string(formatSize(120, 40)), 120, 40, false},
+		{"extra spaces", " 80  24", 80, 24, false},
+		{"only one number", "80", 0, 0, true},
+		{"not numbers", "wide tall", 0, 0, true},
+		{"nothing", "", 0, 0, true},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			cols, rows, err := parseSize([]byte(s.payload))
+			if s.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, s.cols, cols)
+			assert.Equal(t, s.rows, rows)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Package server lets lazysession outlive the terminal it was started from,
+// like abduco or dtach. A background server owns a pty with the lazysession
+// TUI running in it, and with it the wrapped programs, their scrollback and
+// the buffer history. The user's terminal attaches to the server over a Unix
+// socket as a client that passes keypresses in and output back, and can
+// detach and reattach later, from another terminal if need be.
+package server
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jesseduffield/pty"
+)
+
+const (
+	// ServeEnv is set to the socket path for the process that's to be the
+	// server
+	ServeEnv = "LAZYSESSION_SERVE"
+	// SocketEnv is set to the socket path for the TUI the server runs, so that
+	// it knows it can detach
+	SocketEnv = "LAZYSESSION_SERVER_SOCKET"
+)
+
+const socketsDirname = "sessions"
+
+// how long we give a new server to start listening
+const startTimeout = 5 * time.Second
+
+// how long we give a client to take output before we give up on it, e.g.
+// because its ssh connection has stalled
+const clientWriteTimeout = 5 * time.Second
+
+// SocketPath is where the socket for the named session lives
+func SocketPath(configDir string, name string) string {
+	return filepath.Join(configDir, socketsDirname, name+".sock")
+}
+
+// Start runs this binary with the given args as a server in the background,
+// returning once it's ready for a client to attach
+func Start(socketPath string, args []string) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return err
+	}
+	if alive(socketPath) {
+		return errors.New("a session is already running at " + socketPath)
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Env = append(os.Environ(), ServeEnv+"="+socketPath)
+	// a session of its own means the server doesn't get hung up on along with
+	// the terminal that started it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+
+	for deadline := time.Now().Add(startTimeout); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if alive(socketPath) {
+			return nil
+		}
+	}
+	return errors.New("timed out waiting for the lazysession server to start")
+}
+
+// server relays between the pty the TUI is running in and whichever client is
+// attached
+type server struct {
+	ptmx *os.File
+	cmd  *exec.Cmd
+
+	mutex  sync.Mutex
+	client net.Conn
+}
+
+// Serve runs this binary with the given args in a pty and serves it on the
+// socket until it exits
+func Serve(socketPath string, args []string) error {
+	// a socket left behind by a server that crashed would stop us listening
+	_ = os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	defer listener.Close()
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		return err
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Env = append(withoutEnv(os.Environ(), ServeEnv), SocketEnv+"="+socketPath)
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: 80, Rows: 24})
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	s := &server{ptmx: ptmx, cmd: cmd}
+	go s.accept(listener)
+
+	s.relayOutput()
+	_ = cmd.Wait()
+	s.disconnect(ReasonExited)
+	return nil
+}
+
+func (s *server) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle serves a connection, which is either a client attaching or the TUI
+// asking for the client to be detached
+func (s *server) handle(conn net.Conn) {
+	msg, err := readMessage(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	switch msg.kind {
+	case msgAttach:
+		cols, rows, err := parseSize(msg.payload)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		s.attach(conn)
+		s.resize(cols, rows)
+		s.readInput(conn)
+	case msgDetach:
+		s.disconnect(ReasonDetached)
+		conn.Close()
+	default:
+		conn.Close()
+	}
+}
+
+// attach makes conn the client, detaching any other. Only one terminal at a
+// time gets to type into the session.
+func (s *server) attach(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.client != nil {
+		_ = s.client.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+		_ = writeMessage(s.client, msgClose, []byte(ReasonDetached))
+		s.client.Close()
+	}
+	s.client = conn
+}
+
+func (s *server) disconnect(reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.client == nil {
+		return
+	}
+	_ = s.client.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	_ = writeMessage(s.client, msgClose, []byte(reason))
+	s.client.Close()
+	s.client = nil
+}
+
+// readInput passes what the client sends on to the TUI until the client goes
+// away
+func (s *server) readInput(conn net.Conn) {
+	for {
+		msg, err := readMessage(conn)
+		if err != nil {
+			break
+		}
+		switch msg.kind {
+		case msgInput:
+			if _, err := s.ptmx.Write(msg.payload); err != nil {
+				return
+			}
+		case msgResize:
+			if cols, rows, err := parseSize(msg.payload); err == nil {
+				s.resize(cols, rows)
+			}
+		}
+	}
+
+	// the client's connection dropped, e.g. because its ssh session died
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client == conn {
+		s.client = nil
+	}
+}
+
+// resize gives the TUI the client's terminal size. The TUI redraws
+// everything when it gets SIGWINCH, which we send even if the size hasn't
+// changed, because a newly attached terminal has nothing on it yet.
+func (s *server) resize(cols int, rows int) {
+	_ = pty.Setsize(s.ptmx, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	_ = s.cmd.Process.Signal(syscall.SIGWINCH)
+}
+
+// relayOutput sends what the TUI prints to the client, if there is one,
+// until the TUI exits. With no client attached it goes nowhere: the TUI
+// redraws everything when one attaches. We don't hold the mutex while
+// writing, so a client that's stopped reading can't stop another attaching.
+func (s *server) relayOutput() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.ptmx.Read(buf)
+		if n > 0 {
+			s.mutex.Lock()
+			client := s.client
+			s.mutex.Unlock()
+			if client != nil {
+				_ = client.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+				if err := writeMessage(client, msgOutput, buf[:n]); err != nil {
+					s.drop(client)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// drop closes the connection to a client we can't write to, leaving the
+// session without one unless another has attached in the meantime
+func (s *server) drop(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conn.Close()
+	if s.client == conn {
+		s.client = nil
+	}
+}
+
+func withoutEnv(env []string, name string) []string {
+	result := make([]string, 0, len(env))
+	for _, entry := range env {
+		if !strings.HasPrefix(entry, name+"=") {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,12 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithoutEnv(t *testing.T) {
+	env := []string{"A=1", ServeEnv + "=/tmp/x.sock", "AB=2", ServeEnv + "X=3"}
+	assert.Equal(t, []string{"A=1", "AB=2", ServeEnv + "X=3"}, withoutEnv(env, ServeEnv))
+}
@@ -0,0 +1,256 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Color is either DefaultColor, an index into the xterm 256 colour palette, or
+// a 24 bit colour created with RGBColor
+type Color int32
+
+// DefaultColor means the terminal's default foreground or background
+const DefaultColor Color = -1
+
+const rgbFlag Color = 1 << 24
+
+// RGBColor returns a 24 bit colour
+func RGBColor(r, g, b uint8) Color {
+	return rgbFlag | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// IsRGB tells us whether this is a 24 bit colour
+func (c Color) IsRGB() bool {
+	return c != DefaultColor && c&rgbFlag != 0
+}
+
+// RGB returns the components of a 24 bit colour
+func (c Color) RGB() (uint8, uint8, uint8) {
+	return uint8(c >> 16), uint8(c >> 8), uint8(c)
+}
+
+// To256 returns the closest colour in the xterm 256 colour palette
+func (c Color) To256() Color {
+	if !c.IsRGB() {
+		return c
+	}
+
+	r, g, b := c.RGB()
+	if r == g && g == b {
+		if r < 8 {
+			return 16
+		}
+		if r > 238 {
+			return 231
+		}
+		return Color(232 + (int(r)-8)/10)
+	}
+
+	toLevel := func(v uint8) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (int(v) - 35) / 40
+	}
+	return Color(16 + 36*toLevel(r) + 6*toLevel(g) + toLevel(b))
+}
+
+// Flag is a text style like bold or underline
+type Flag uint8
+
+// Text styles
+const (
+	Bold Flag = 1 << iota
+	Dim
+	Italic
+	Underline
+	Blink
+	Reverse
+	Hidden
+	Strikethrough
+)
+
+// Attr is how a cell is drawn
+type Attr struct {
+	Fg    Color
+	Bg    Color
+	Flags Flag
+}
+
+// DefaultAttr is what cells look like before any SGR sequences are applied
+var DefaultAttr = Attr{Fg: DefaultColor, Bg: DefaultColor}
+
+// continuation marks the cell to the right of a double width character
+const continuation rune = -1
+
+// Cell is a single character on the screen
+type Cell struct {
+	Ch   rune
+	Attr Attr
+}
+
+func blankCell(attr Attr) Cell {
+	// erasing only keeps the background colour
+	return Cell{Ch: ' ', Attr: Attr{Fg: DefaultColor, Bg: attr.Bg}}
+}
+
+// isBlank tells us whether the cell would look empty when drawn
+func (c Cell) isBlank() bool {
+	if c.Ch != ' ' && c.Ch != 0 {
+		return false
+	}
+	return c.Attr.Bg == DefaultColor && c.Attr.Flags&(Reverse|Underline|Strikethrough) == 0
+}
+
+// Line is a row of cells, either on the screen or in the scrollback
+type Line struct {
+	Cells []Cell
+	// Wrapped is true when the text ran past the right margin onto the next line
+	Wrapped bool
+	// Time is when the program first printed something on the line. It's zero
+	// if it never has.
+	Time time.Time
+}
+
+func newLine(cols int, attr Attr) *Line {
+	line := &Line{Cells: make([]Cell, cols)}
+	line.clear(0, cols, attr)
+	return line
+}
+
+func (l *Line) clear(from, to int, attr Attr) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(l.Cells) {
+		to = len(l.Cells)
+	}
+	for i := from; i < to; i++ {
+		l.Cells[i] = blankCell(attr)
+	}
+}
+
+func (l *Line) resize(cols int) {
+	if cols <= len(l.Cells) {
+		l.Cells = l.Cells[:cols]
+		return
+	}
+	for len(l.Cells) < cols {
+		l.Cells = append(l.Cells, blankCell(DefaultAttr))
+	}
+}
+
+func (l *Line) copy() Line {
+	cells := make([]Cell, len(l.Cells))
+	copy(cells, l.Cells)
+	return Line{Cells: cells, Wrapped: l.Wrapped, Time: l.Time}
+}
+
+// length returns the number of cells up to and including the last one that
+// isn't blank
+func (l *Line) length() int {
+	for i := len(l.Cells) - 1; i >= 0; i-- {
+		if !l.Cells[i].isBlank() {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// String returns the text of the line without trailing blanks
+func (l *Line) String() string {
+	var builder strings.Builder
+	for _, c := range l.Cells[:l.length()] {
+		switch {
+		case c.Ch == continuation:
+		case c.Ch == 0:
+			builder.WriteRune(' ')
+		default:
+			builder.WriteRune(c.Ch)
+		}
+	}
+	return builder.String()
+}
+
+// TextWithCells returns the text of the line, as String does, along with the
+// index of the cell that each byte of the text came from. There's an extra
+// index at the end for the cell just past the text, so that a range of bytes
+// [i, j) covers cells [cells[i], cells[j]).
+func (l *Line) TextWithCells() (string, []int) {
+	var builder strings.Builder
+	cells := []int{}
+	length := l.length()
+	for i, c := range l.Cells[:length] {
+		ch := c.Ch
+		switch {
+		case ch == continuation:
+			continue
+		case ch == 0:
+			ch = ' '
+		}
+		before := builder.Len()
+		builder.WriteRune(ch)
+		for j := before; j < builder.Len(); j++ {
+			cells = append(cells, i)
+		}
+	}
+	return builder.String(), append(cells, length)
+}
+
+// ANSI returns the text of the line with SGR sequences for its colours and
+// styles. Each attribute gets its own sequence, after a reset, so that simple
+// escape code interpreters like gocui's can follow along.
+func (l *Line) ANSI() string {
+	var builder strings.Builder
+	current := DefaultAttr
+	for _, c := range l.Cells[:l.length()] {
+		if c.Ch == continuation {
+			continue
+		}
+		if c.Attr != current {
+			builder.WriteString(sgr(c.Attr))
+			current = c.Attr
+		}
+		switch {
+		case c.Ch == 0, c.Attr.Flags&Hidden != 0:
+			builder.WriteRune(' ')
+		default:
+			builder.WriteRune(c.Ch)
+		}
+	}
+	if current != DefaultAttr {
+		builder.WriteString("\x1b[0m")
+	}
+	return builder.String()
+}
+
+func sgr(attr Attr) string {
+	var builder strings.Builder
+	builder.WriteString("\x1b[0m")
+	if attr.Fg != DefaultColor {
+		fmt.Fprintf(&builder, "\x1b[38;5;%dm", attr.Fg.To256())
+	}
+	if attr.Bg != DefaultColor {
+		fmt.Fprintf(&builder, "\x1b[48;5;%dm", attr.Bg.To256())
+	}
+	for _, style := range []struct {
+		flag Flag
+		code int
+	}{
+		{Bold, 1},
+		{Dim, 2},
+		{Italic, 3},
+		{Underline, 4},
+		{Reverse, 7},
+		{Strikethrough, 9},
+	} {
+		if attr.Flags&style.flag != 0 {
+			fmt.Fprintf(&builder, "\x1b[%dm", style.code)
+		}
+	}
+	return builder.String()
+}
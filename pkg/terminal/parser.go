@@ -0,0 +1,560 @@
+package terminal
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// parser states, following https://vt100.net/emu/dec_ansi_parser
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	// DCS, SOS, PM and APC strings are read and thrown away
+	stateIgnoreString
+	stateIgnoreStringEscape
+)
+
+const maxParams = 32
+const maxOSCLength = 4096
+
+type parser struct {
+	state parserState
+
+	params        []int
+	currentParam  int
+	hasParam      bool
+	private       byte
+	intermediates []byte
+	osc           []byte
+
+	// bytes of a UTF-8 character that hasn't been completed yet
+	utf8 []byte
+}
+
+func (p *parser) clear() {
+	p.params = p.params[:0]
+	p.currentParam = 0
+	p.hasParam = false
+	p.private = 0
+	p.intermediates = p.intermediates[:0]
+}
+
+func (p *parser) feed(s *Screen, b byte) {
+	// these can interrupt any sequence
+	switch b {
+	case 0x18, 0x1a: // CAN, SUB
+		p.state = stateGround
+		p.utf8 = p.utf8[:0]
+		return
+	case 0x1b:
+		switch p.state {
+		case stateOSC:
+			p.state = stateOSCEscape
+		case stateIgnoreString:
+			p.state = stateIgnoreStringEscape
+		default:
+			p.state = stateEscape
+			p.clear()
+		}
+		p.utf8 = p.utf8[:0]
+		return
+	}
+
+	switch p.state {
+	case stateGround:
+		p.ground(s, b)
+	case stateEscape:
+		p.escape(s, b)
+	case stateEscapeIntermediate:
+		p.escapeIntermediate(s, b)
+	case stateCSI:
+		p.csi(s, b)
+	case stateOSC:
+		p.oscByte(s, b)
+	case stateOSCEscape:
+		// ESC \ ends the string; anything else after ESC also ends it and
+		// starts a new sequence
+		s.osc(string(p.osc))
+		p.state = stateEscape
+		p.clear()
+		if b != '\\' {
+			p.escape(s, b)
+		} else {
+			p.state = stateGround
+		}
+	case stateIgnoreString:
+		if b == 0x07 {
+			p.state = stateGround
+		}
+	case stateIgnoreStringEscape:
+		p.state = stateEscape
+		p.clear()
+		if b != '\\' {
+			p.escape(s, b)
+		} else {
+			p.state = stateGround
+		}
+	}
+}
+
+func (p *parser) ground(s *Screen, b byte) {
+	if len(p.utf8) == 0 {
+		if b < 0x20 || b == 0x7f {
+			execute(s, b)
+			return
+		}
+		if b < 0x80 {
+			s.print(rune(b))
+			return
+		}
+	}
+
+	p.utf8 = append(p.utf8, b)
+	if !utf8.FullRune(p.utf8) {
+		return
+	}
+	r, _ := utf8.DecodeRune(p.utf8)
+	p.utf8 = p.utf8[:0]
+	s.print(r)
+}
+
+func execute(s *Screen, b byte) {
+	switch b {
+	case 0x07:
+		s.bell()
+	case 0x08:
+		s.backspace()
+	case 0x09:
+		s.tab(1)
+	case 0x0a, 0x0b, 0x0c:
+		s.lineFeed()
+	case 0x0d:
+		s.carriageReturn()
+	case 0x0e: // SO
+		s.cursor.gl = 1
+	case 0x0f: // SI
+		s.cursor.gl = 0
+	}
+}
+
+func (p *parser) escape(s *Screen, b byte) {
+	switch {
+	case b < 0x20:
+		execute(s, b)
+		return
+	case b >= 0x20 && b <= 0x2f:
+		p.intermediates = append(p.intermediates, b)
+		p.state = stateEscapeIntermediate
+		return
+	}
+
+	p.state = stateGround
+	switch b {
+	case '[':
+		p.clear()
+		p.state = stateCSI
+	case ']':
+		p.osc = p.osc[:0]
+		p.state = stateOSC
+	case 'P', 'X', '^', '_':
+		p.state = stateIgnoreString
+	case '7':
+		s.saveCursor()
+	case '8':
+		s.restoreCursor()
+	case 'D':
+		s.index()
+	case 'E':
+		s.carriageReturn()
+		s.index()
+	case 'H':
+		if s.cursor.x < s.cols {
+			s.tabStops[s.cursor.x] = true
+		}
+	case 'M':
+		s.reverseIndex()
+	case 'c':
+		s.title = ""
+		s.clearScrollback()
+		s.reset()
+	case 'n': // LS2
+		s.cursor.gl = 2
+	case 'o': // LS3
+		s.cursor.gl = 3
+	}
+}
+
+func (p *parser) escapeIntermediate(s *Screen, b byte) {
+	if b < 0x20 {
+		execute(s, b)
+		return
+	}
+	if b <= 0x2f {
+		p.intermediates = append(p.intermediates, b)
+		return
+	}
+
+	p.state = stateGround
+	switch p.intermediates[0] {
+	case '(', ')', '*', '+':
+		set := charsetASCII
+		switch b {
+		case '0':
+			set = charsetDECGraphics
+		case 'A':
+			set = charsetUK
+		}
+		s.cursor.charsets[p.intermediates[0]-'('] = set
+	case '#':
+		if b == '8' {
+			s.fillWithE()
+		}
+	}
+}
+
+func (p *parser) csi(s *Screen, b byte) {
+	switch {
+	case b < 0x20:
+		execute(s, b)
+	case b >= '0' && b <= '9':
+		if p.currentParam < 100000 {
+			p.currentParam = p.currentParam*10 + int(b-'0')
+		}
+		p.hasParam = true
+	case b == ';' || b == ':':
+		p.pushParam()
+	case b >= '<' && b <= '?':
+		p.private = b
+	case b >= 0x20 && b <= 0x2f:
+		p.intermediates = append(p.intermediates, b)
+	case b >= 0x40 && b <= 0x7e:
+		p.pushParam()
+		p.state = stateGround
+		p.dispatchCSI(s, b)
+	default:
+		p.state = stateGround
+	}
+}
+
+func (p *parser) pushParam() {
+	if len(p.params) < maxParams {
+		value := p.currentParam
+		if !p.hasParam {
+			value = -1
+		}
+		p.params = append(p.params, value)
+	}
+	p.currentParam = 0
+	p.hasParam = false
+}
+
+func (p *parser) oscByte(s *Screen, b byte) {
+	if b == 0x07 {
+		s.osc(string(p.osc))
+		p.state = stateGround
+		return
+	}
+	if b < 0x20 {
+		return
+	}
+	if len(p.osc) < maxOSCLength {
+		p.osc = append(p.osc, b)
+	}
+}
+
+// param returns the i'th parameter, or def if it's missing or zero
+func (p *parser) param(i int, def int) int {
+	if i >= len(p.params) || p.params[i] <= 0 {
+		return def
+	}
+	return p.params[i]
+}
+
+func (p *parser) dispatchCSI(s *Screen, final byte) {
+	if p.private == '?' {
+		p.dispatchPrivateCSI(s, final)
+		return
+	}
+	if p.private != 0 {
+		if p.private == '>' && final == 'c' {
+			// secondary device attributes: we claim to be a VT220 like xterm does
+			s.respond("\x1b[>1;10;0c")
+		}
+		return
+	}
+	if len(p.intermediates) > 0 {
+		if p.intermediates[0] == '!' && final == 'p' {
+			s.softReset()
+		}
+		return
+	}
+
+	switch final {
+	case '@':
+		s.insertBlanks(p.param(0, 1))
+	case 'A':
+		s.moveCursorVertically(-p.param(0, 1))
+	case 'B', 'e':
+		s.moveCursorVertically(p.param(0, 1))
+	case 'C', 'a':
+		s.cursor.x = clamp(s.cursor.x+p.param(0, 1), 0, s.cols-1)
+		s.cursor.pendingWrap = false
+	case 'D':
+		s.cursor.x = clamp(s.cursor.x-p.param(0, 1), 0, s.cols-1)
+		s.cursor.pendingWrap = false
+	case 'E':
+		s.moveCursorVertically(p.param(0, 1))
+		s.cursor.x = 0
+	case 'F':
+		s.moveCursorVertically(-p.param(0, 1))
+		s.cursor.x = 0
+	case 'G', '`':
+		s.cursor.x = clamp(p.param(0, 1)-1, 0, s.cols-1)
+		s.cursor.pendingWrap = false
+	case 'H', 'f':
+		s.moveCursor(p.param(1, 1)-1, p.param(0, 1)-1)
+	case 'I':
+		s.tab(p.param(0, 1))
+	case 'J':
+		s.eraseInDisplay(p.param(0, 0))
+	case 'K':
+		s.eraseInLine(p.param(0, 0))
+	case 'L':
+		s.insertLines(p.param(0, 1))
+	case 'M':
+		s.deleteLines(p.param(0, 1))
+	case 'P':
+		s.deleteChars(p.param(0, 1))
+	case 'S':
+		s.scrollUp(p.param(0, 1))
+	case 'T':
+		s.scrollDown(p.param(0, 1))
+	case 'X':
+		s.eraseChars(p.param(0, 1))
+	case 'Z':
+		s.backTab(p.param(0, 1))
+	case 'b':
+		if s.lastPrinted != 0 {
+			for i := p.param(0, 1); i > 0; i-- {
+				s.print(s.lastPrinted)
+			}
+		}
+	case 'c':
+		if p.param(0, 0) == 0 {
+			// primary device attributes: a VT100 with advanced video
+			s.respond("\x1b[?1;2c")
+		}
+	case 'd':
+		s.moveCursor(s.cursor.x, p.param(0, 1)-1)
+	case 'g':
+		switch p.param(0, 0) {
+		case 0:
+			s.tabStops[s.cursor.x] = false
+		case 3:
+			s.tabStops = make([]bool, s.cols)
+		}
+	case 'h', 'l':
+		set := final == 'h'
+		for i := range p.params {
+			switch p.params[i] {
+			case 4:
+				s.insertMode = set
+			case 20:
+				s.newlineMode = set
+			}
+		}
+	case 'm':
+		s.selectGraphicRendition(p.params)
+	case 'n':
+		switch p.param(0, 0) {
+		case 5:
+			s.respond("\x1b[0n")
+		case 6:
+			y := s.cursor.y
+			if s.cursor.originMode {
+				y -= s.scrollTop
+			}
+			s.respond(fmt.Sprintf("\x1b[%d;%dR", y+1, s.cursor.x+1))
+		}
+	case 'r':
+		s.setScrollRegion(p.param(0, 1)-1, p.param(1, s.rows)-1)
+	case 's':
+		s.saveCursor()
+	case 'u':
+		s.restoreCursor()
+	}
+}
+
+func (p *parser) dispatchPrivateCSI(s *Screen, final byte) {
+	if final != 'h' && final != 'l' {
+		return
+	}
+
+	set := final == 'h'
+	for _, mode := range p.params {
+		switch mode {
+		case 1:
+			s.appCursorKeys = set
+		case 3:
+			// DECCOLM: we can't change our width but xterm clears the screen
+			s.eraseInDisplay(2)
+			s.moveCursor(0, 0)
+		case 6:
+			s.cursor.originMode = set
+			s.moveCursor(0, 0)
+		case 7:
+			s.autowrap = set
+		case 25:
+			s.cursorVisible = set
+		case 9, 1000, 1002, 1003:
+			if set {
+				s.mouseMode = MouseMode(mode)
+			} else {
+				s.mouseMode = MouseNone
+			}
+		case 1004:
+			s.focusReporting = set
+		case 1005, 1006, 1015:
+			if set {
+				s.mouseEncoding = MouseEncoding(mode)
+			} else if s.mouseEncoding == MouseEncoding(mode) {
+				s.mouseEncoding = MouseEncodingDefault
+			}
+		case 47, 1047:
+			if set {
+				s.enterAltScreen(false, false)
+			} else {
+				if mode == 1047 && s.altScreen {
+					s.eraseInDisplay(2)
+				}
+				s.exitAltScreen(false)
+			}
+		case 1048:
+			if set {
+				s.saveCursor()
+			} else {
+				s.restoreCursor()
+			}
+		case 1049:
+			if set {
+				s.enterAltScreen(true, true)
+			} else {
+				s.exitAltScreen(true)
+			}
+		case 2004:
+			s.bracketedPaste = set
+		}
+	}
+}
+
+func (s *Screen) softReset() {
+	s.cursorVisible = true
+	s.insertMode = false
+	s.cursor.originMode = false
+	s.autowrap = true
+	s.appCursorKeys = false
+	s.scrollTop = 0
+	s.scrollBottom = s.rows - 1
+	s.cursor.attr = DefaultAttr
+	s.cursor.charsets = [4]charset{}
+	s.cursor.gl = 0
+	s.savedCursor = cursor{attr: DefaultAttr}
+}
+
+// selectGraphicRendition handles SGR sequences. Missing parameters count as 0.
+func (s *Screen) selectGraphicRendition(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+
+	attr := &s.cursor.attr
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		if param < 0 {
+			param = 0
+		}
+		switch {
+		case param == 0:
+			*attr = DefaultAttr
+		case param == 1:
+			attr.Flags |= Bold
+		case param == 2:
+			attr.Flags |= Dim
+		case param == 3:
+			attr.Flags |= Italic
+		case param == 4:
+			attr.Flags |= Underline
+		case param == 5 || param == 6:
+			attr.Flags |= Blink
+		case param == 7:
+			attr.Flags |= Reverse
+		case param == 8:
+			attr.Flags |= Hidden
+		case param == 9:
+			attr.Flags |= Strikethrough
+		case param == 21 || param == 22:
+			attr.Flags &^= Bold | Dim
+		case param == 23:
+			attr.Flags &^= Italic
+		case param == 24:
+			attr.Flags &^= Underline
+		case param == 25:
+			attr.Flags &^= Blink
+		case param == 27:
+			attr.Flags &^= Reverse
+		case param == 28:
+			attr.Flags &^= Hidden
+		case param == 29:
+			attr.Flags &^= Strikethrough
+		case param >= 30 && param <= 37:
+			attr.Fg = Color(param - 30)
+		case param == 38:
+			var color Color
+			color, i = extendedColor(params, i)
+			if color != DefaultColor {
+				attr.Fg = color
+			}
+		case param == 39:
+			attr.Fg = DefaultColor
+		case param >= 40 && param <= 47:
+			attr.Bg = Color(param - 40)
+		case param == 48:
+			var color Color
+			color, i = extendedColor(params, i)
+			if color != DefaultColor {
+				attr.Bg = color
+			}
+		case param == 49:
+			attr.Bg = DefaultColor
+		case param >= 90 && param <= 97:
+			attr.Fg = Color(param - 90 + 8)
+		case param >= 100 && param <= 107:
+			attr.Bg = Color(param - 100 + 8)
+		}
+	}
+}
+
+// extendedColor reads a 38/48 colour starting at params[i], returning the
+// colour and the index of the last parameter it used
+func extendedColor(params []int, i int) (Color, int) {
+	get := func(j int) int {
+		if j >= len(params) || params[j] < 0 {
+			return 0
+		}
+		return params[j]
+	}
+
+	switch get(i + 1) {
+	case 5:
+		return Color(clamp(get(i+2), 0, 255)), i + 2
+	case 2:
+		return RGBColor(uint8(get(i+2)), uint8(get(i+3)), uint8(get(i+4))), i + 4
+	}
+	return DefaultColor, i + 1
+}
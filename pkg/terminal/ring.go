@@ -0,0 +1,115 @@
+package terminal
+
+import "unsafe"
+
+// lineBytes is roughly how much memory a line takes up
+func lineBytes(line *Line) int {
+	return int(unsafe.Sizeof(*line)) + len(line.Cells)*int(unsafe.Sizeof(Cell{}))
+}
+
+// ring holds the most recent lines of scrollback. Once it's holding maxLines
+// lines or maxBytes bytes, the oldest lines are evicted to make room. A limit
+// of zero means no limit.
+type ring struct {
+	lines []*Line
+	// start is the index in lines of the oldest line
+	start int
+	count int
+	bytes int
+
+	maxLines int
+	maxBytes int
+}
+
+func (r *ring) len() int {
+	return r.count
+}
+
+// at returns the i'th oldest line
+func (r *ring) at(i int) *Line {
+	return r.lines[(r.start+i)%len(r.lines)]
+}
+
+// push adds a line, returning any lines that were evicted to make room for it
+func (r *ring) push(line *Line) []*Line {
+	var evicted []*Line
+	if r.maxLines > 0 && r.count >= r.maxLines {
+		evicted = append(evicted, r.shift())
+	}
+	if r.count == len(r.lines) {
+		r.grow()
+	}
+
+	r.lines[(r.start+r.count)%len(r.lines)] = line
+	r.count++
+	r.bytes += lineBytes(line)
+
+	return append(evicted, r.evictOverLimit()...)
+}
+
+// pop removes and returns the newest line
+func (r *ring) pop() *Line {
+	if r.count == 0 {
+		return nil
+	}
+	index := (r.start + r.count - 1) % len(r.lines)
+	line := r.lines[index]
+	r.lines[index] = nil
+	r.count--
+	r.bytes -= lineBytes(line)
+	return line
+}
+
+// shift removes and returns the oldest line
+func (r *ring) shift() *Line {
+	if r.count == 0 {
+		return nil
+	}
+	line := r.lines[r.start]
+	r.lines[r.start] = nil
+	r.start = (r.start + 1) % len(r.lines)
+	r.count--
+	r.bytes -= lineBytes(line)
+	return line
+}
+
+// clear removes and returns every line, oldest first
+func (r *ring) clear() []*Line {
+	evicted := make([]*Line, 0, r.count)
+	for r.count > 0 {
+		evicted = append(evicted, r.shift())
+	}
+	return evicted
+}
+
+// setLimits changes the limits, returning any lines that no longer fit
+func (r *ring) setLimits(maxLines int, maxBytes int) []*Line {
+	r.maxLines = maxLines
+	r.maxBytes = maxBytes
+	return r.evictOverLimit()
+}
+
+func (r *ring) evictOverLimit() []*Line {
+	var evicted []*Line
+	for r.count > 0 && ((r.maxLines > 0 && r.count > r.maxLines) || (r.maxBytes > 0 && r.bytes > r.maxBytes)) {
+		evicted = append(evicted, r.shift())
+	}
+	return evicted
+}
+
+func (r *ring) grow() {
+	capacity := len(r.lines) * 2
+	if capacity == 0 {
+		capacity = 64
+	}
+	if r.maxLines > 0 && capacity > r.maxLines {
+		capacity = r.maxLines
+	}
+
+	lines := make([]*Line, capacity)
+	for i := 0; i < r.count; i++ {
+		lines[i] = r.at(i)
+	}
+	r.lines = lines
+	r.start = 0
+}
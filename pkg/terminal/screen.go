@@ -0,0 +1,909 @@
+// Package terminal emulates an xterm-compatible terminal: it turns the bytes
+// a program writes to its pty into a grid of cells, along with a scrollback
+// of the lines that have scrolled off the top.
+package terminal
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// MouseMode is the kind of mouse events the program has asked for
+type MouseMode int
+
+// Mouse modes, see https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h2-Mouse-Tracking
+const (
+	MouseNone   MouseMode = 0
+	MouseX10    MouseMode = 9
+	MouseNormal MouseMode = 1000
+	MouseButton MouseMode = 1002
+	MouseAny    MouseMode = 1003
+)
+
+// MouseEncoding is how the program wants mouse events encoded
+type MouseEncoding int
+
+// Mouse encodings
+const (
+	MouseEncodingDefault MouseEncoding = 0
+	MouseEncodingUTF8    MouseEncoding = 1005
+	MouseEncodingSGR     MouseEncoding = 1006
+	MouseEncodingURXVT   MouseEncoding = 1015
+)
+
+type charset int
+
+const (
+	charsetASCII charset = iota
+	charsetDECGraphics
+	charsetUK
+)
+
+// cursor is everything that DECSC saves and DECRC restores
+type cursor struct {
+	x, y        int
+	attr        Attr
+	originMode  bool
+	charsets    [4]charset
+	gl          int
+	pendingWrap bool
+}
+
+// Screen is a terminal screen. It is safe to use from multiple goroutines.
+type Screen struct {
+	mutex sync.Mutex
+
+	cols, rows int
+	lines      []*Line
+	scrollback ring
+	// dropped is how many lines have fallen out of the scrollback. Lines are
+	// numbered from the first line the program ever wrote, so the first line
+	// still in the scrollback is numbered dropped.
+	dropped int
+	spill   *Spill
+	// now is when the output we're handling arrived. It's what we give lines
+	// as their Time.
+	now   time.Time
+	clock func() time.Time
+
+	cursor
+	savedCursor cursor
+
+	// while we're on the alternate screen, the primary screen is kept here
+	altScreen        bool
+	primaryLines     []*Line
+	primarySavedCurs cursor
+	primaryCursor    cursor
+	scrollTop        int
+	scrollBottom     int
+	tabStops         []bool
+	lastPrinted      rune
+	autowrap         bool
+	insertMode       bool
+	newlineMode      bool
+	cursorVisible    bool
+	appCursorKeys    bool
+	bracketedPaste   bool
+	focusReporting   bool
+	mouseMode        MouseMode
+	mouseEncoding    MouseEncoding
+	title            string
+	changes          uint64
+	responses        io.Writer
+	pendingCallbacks []func()
+	parser           parser
+
+	// OnOSC is called with the body of any OSC sequence that we don't handle
+	// ourselves, along with where the cursor was when it arrived. x is the
+	// column and line is the line number, as used by Lines.
+	OnOSC func(body string, x int, line int)
+	// OnBell is called when the program rings the bell
+	OnBell func()
+	// OnAltScreen is called when the program switches to or from the
+	// alternate screen
+	OnAltScreen func(active bool)
+}
+
+// New returns a screen of the given size
+func New(cols, rows int) *Screen {
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+
+	s := &Screen{
+		cols:  cols,
+		rows:  rows,
+		clock: time.Now,
+	}
+	s.reset()
+	return s
+}
+
+// SetResponseWriter sets where replies to queries like cursor position
+// reports go. This should be the pty.
+func (s *Screen) SetResponseWriter(w io.Writer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.responses = w
+}
+
+func (s *Screen) reset() {
+	s.lines = make([]*Line, s.rows)
+	for i := range s.lines {
+		s.lines[i] = newLine(s.cols, DefaultAttr)
+	}
+	s.cursor = cursor{attr: DefaultAttr}
+	s.savedCursor = s.cursor
+	s.altScreen = false
+	s.primaryLines = nil
+	s.scrollTop = 0
+	s.scrollBottom = s.rows - 1
+	s.resetTabStops()
+	s.autowrap = true
+	s.insertMode = false
+	s.newlineMode = false
+	s.cursorVisible = true
+	s.appCursorKeys = false
+	s.bracketedPaste = false
+	s.focusReporting = false
+	s.mouseMode = MouseNone
+	s.mouseEncoding = MouseEncodingDefault
+	s.changes++
+}
+
+func (s *Screen) resetTabStops() {
+	s.tabStops = make([]bool, s.cols)
+	for i := 8; i < s.cols; i += 8 {
+		s.tabStops[i] = true
+	}
+}
+
+// Reset puts the screen back the way it was when it was created, scrollback
+// included
+func (s *Screen) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.parser = parser{}
+	s.title = ""
+	s.scrollback.clear()
+	s.dropped = 0
+	s.reset()
+}
+
+// SetScrollbackLimit limits the scrollback to the given number of lines and
+// the given number of bytes of memory. Zero means no limit.
+func (s *Screen) SetScrollbackLimit(lines int, bytes int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.drop(s.scrollback.setLimits(lines, bytes))
+}
+
+// SetSpill sets a file for lines that fall out of the scrollback to go to
+func (s *Screen) SetSpill(spill *Spill) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.spill = spill
+}
+
+// Write feeds output from the program into the screen
+func (s *Screen) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	s.now = s.clock()
+	for _, b := range p {
+		s.parser.feed(s, b)
+	}
+	s.changes++
+	callbacks := s.pendingCallbacks
+	s.pendingCallbacks = nil
+	s.mutex.Unlock()
+
+	// callbacks are run without the lock held so that they can query the screen
+	for _, callback := range callbacks {
+		callback()
+	}
+
+	return len(p), nil
+}
+
+// respond queues a reply to be written once we've let go of the lock. Writing
+// to the pty can block until the program reads, and the program may be
+// blocked writing output that needs the lock.
+func (s *Screen) respond(response string) {
+	if s.responses == nil {
+		return
+	}
+	w := s.responses
+	s.pendingCallbacks = append(s.pendingCallbacks, func() { _, _ = w.Write([]byte(response)) })
+}
+
+// Resize changes the size of the screen. If the screen gets shorter, lines
+// above the cursor are pushed into the scrollback.
+func (s *Screen) Resize(cols, rows int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+
+	s.lines, s.cursor.y = s.resizeLines(s.lines, s.cursor.y, cols, rows, !s.altScreen)
+	if s.altScreen {
+		s.primaryLines, s.primaryCursor.y = s.resizeLines(s.primaryLines, s.primaryCursor.y, cols, rows, true)
+		s.primaryCursor.x = clamp(s.primaryCursor.x, 0, cols-1)
+	}
+
+	s.cols = cols
+	s.rows = rows
+	s.cursor.x = clamp(s.cursor.x, 0, cols-1)
+	s.cursor.pendingWrap = false
+	s.savedCursor.x = clamp(s.savedCursor.x, 0, cols-1)
+	s.savedCursor.y = clamp(s.savedCursor.y, 0, rows-1)
+	s.scrollTop = 0
+	s.scrollBottom = rows - 1
+	s.resetTabStops()
+	s.changes++
+}
+
+func (s *Screen) resizeLines(lines []*Line, cursorY int, cols, rows int, primary bool) ([]*Line, int) {
+	for _, line := range lines {
+		line.resize(cols)
+	}
+
+	for len(lines) > rows {
+		if cursorY < len(lines)-1 {
+			// drop blank lines from below the cursor first
+			lines = lines[:len(lines)-1]
+			continue
+		}
+		if primary {
+			s.pushScrollback(lines[0])
+		}
+		lines = lines[1:]
+		cursorY--
+	}
+
+	for len(lines) < rows {
+		if primary && s.scrollback.len() > 0 && !s.altScreen {
+			// pull lines back out of the scrollback, like xterm does
+			line := s.scrollback.pop()
+			line.resize(cols)
+			lines = append([]*Line{line}, lines...)
+			cursorY++
+			continue
+		}
+		lines = append(lines, newLine(cols, DefaultAttr))
+	}
+
+	return lines, clamp(cursorY, 0, rows-1)
+}
+
+// pushScrollback adds a copy of the line to the scrollback, leaving the line
+// itself free to be reused
+func (s *Screen) pushScrollback(line *Line) {
+	// trailing blanks are put back by resize if the line returns to the screen
+	cells := append([]Cell(nil), line.Cells[:line.length()]...)
+	s.drop(s.scrollback.push(&Line{Cells: cells, Wrapped: line.Wrapped, Time: line.Time}))
+}
+
+// drop records lines that have fallen out of the scrollback, oldest first
+func (s *Screen) drop(lines []*Line) {
+	if len(lines) == 0 {
+		return
+	}
+	s.dropped += len(lines)
+	if s.spill != nil {
+		s.spill.append(lines)
+	}
+}
+
+// clearScrollback empties the scrollback, as if the lines had fallen out of it
+func (s *Screen) clearScrollback() {
+	s.drop(s.scrollback.clear())
+}
+
+// Size returns the number of columns and rows
+func (s *Screen) Size() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cols, s.rows
+}
+
+// Changes returns a counter that goes up whenever the screen changes, so that
+// callers can tell whether they need to redraw
+func (s *Screen) Changes() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.changes
+}
+
+// LineCount returns the number of lines written so far, i.e. one more than
+// the number of the last line on the screen. This includes lines that have
+// fallen out of the scrollback.
+func (s *Screen) LineCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped + s.scrollback.len() + len(s.lines)
+}
+
+// FirstLine returns the number of the oldest line still in the scrollback
+func (s *Screen) FirstLine() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// Lines returns copies of lines from the scrollback followed by the screen,
+// from start up to but not including end. Lines that have fallen out of the
+// scrollback are left out.
+func (s *Screen) Lines(start, end int) []Line {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	total := s.dropped + s.scrollback.len() + len(s.lines)
+	start = clamp(start, s.dropped, total)
+	end = clamp(end, start, total)
+
+	result := make([]Line, 0, end-start)
+	for i := start; i < end; i++ {
+		result = append(result, s.line(i).copy())
+	}
+	return result
+}
+
+func (s *Screen) line(i int) *Line {
+	i -= s.dropped
+	if i < s.scrollback.len() {
+		return s.scrollback.at(i)
+	}
+	return s.lines[i-s.scrollback.len()]
+}
+
+// Text returns the text of every line still in memory, scrollback included
+func (s *Screen) Text() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	total := s.dropped + s.scrollback.len() + len(s.lines)
+	result := make([]string, 0, total-s.dropped)
+	for i := s.dropped; i < total; i++ {
+		result = append(result, s.line(i).String())
+	}
+	return result
+}
+
+// HistoryLine is a line returned by History
+type HistoryLine struct {
+	Text string
+	// Time is when the program first printed on the line, or zero
+	Time time.Time
+}
+
+// History returns every line that's been written, starting with the ones in
+// the spill file, along with the number of the first line returned. Lines
+// that fell out of the scrollback while there was no spill file are missing.
+// If ansi is true, the lines include SGR sequences for their colours and
+// styles.
+func (s *Screen) History(ansi bool) ([]HistoryLine, int, error) {
+	result := []HistoryLine{}
+	first, err := s.EachHistoryLine(ansi, func(line HistoryLine) error {
+		result = append(result, line)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, first, nil
+}
+
+// EachHistoryLine is like History but hands the lines to fn one at a time,
+// so that the spill file never has to be in memory all at once. It stops at
+// the first error fn returns. The spill file is read without holding up
+// Write, so lines written in the meantime aren't included.
+func (s *Screen) EachHistoryLine(ansi bool, fn func(HistoryLine) error) (int, error) {
+	s.mutex.Lock()
+	spill := s.spill
+	spilled := 0
+	if spill != nil {
+		spilled = spill.Len()
+	}
+	// if the spill file was set late it won't have the earliest lines
+	first := s.dropped - spilled
+	total := s.dropped + s.scrollback.len() + len(s.lines)
+	inMemory := make([]HistoryLine, 0, total-s.dropped)
+	for i := s.dropped; i < total; i++ {
+		line := s.line(i)
+		text := line.String()
+		if ansi {
+			text = line.ANSI()
+		}
+		inMemory = append(inMemory, HistoryLine{Text: text, Time: line.Time})
+	}
+	s.mutex.Unlock()
+
+	if spill != nil {
+		err := spill.each(spilled, func(line HistoryLine) error {
+			if !ansi {
+				line.Text = stripSGR(line.Text)
+			}
+			return fn(line)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	for _, line := range inMemory {
+		if err := fn(line); err != nil {
+			return 0, err
+		}
+	}
+	return first, nil
+}
+
+// ScreenText returns the text of the rows currently on the screen
+func (s *Screen) ScreenText() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]string, len(s.lines))
+	for i, line := range s.lines {
+		result[i] = line.String()
+	}
+	return result
+}
+
+// Cursor returns the cursor's position on the screen
+func (s *Screen) Cursor() (int, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cursor.x, s.cursor.y
+}
+
+// CursorLine returns the number of the line the cursor is on
+func (s *Screen) CursorLine() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped + s.scrollback.len() + s.cursor.y
+}
+
+// CursorVisible tells us whether the program wants the cursor shown
+func (s *Screen) CursorVisible() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.cursorVisible
+}
+
+// AltScreen tells us whether the program is using the alternate screen
+func (s *Screen) AltScreen() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.altScreen
+}
+
+// AppCursorKeys tells us whether arrow keys should be sent as SS3 sequences
+func (s *Screen) AppCursorKeys() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.appCursorKeys
+}
+
+// BracketedPaste tells us whether pasted text should be wrapped in markers
+func (s *Screen) BracketedPaste() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bracketedPaste
+}
+
+// FocusReporting tells us whether the program wants focus in/out events
+func (s *Screen) FocusReporting() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.focusReporting
+}
+
+// Mouse returns the mouse mode and encoding the program has asked for
+func (s *Screen) Mouse() (MouseMode, MouseEncoding) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.mouseMode, s.mouseEncoding
+}
+
+// Title returns the window title set by the program
+func (s *Screen) Title() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.title
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func (s *Screen) currentLine() *Line {
+	return s.lines[s.cursor.y]
+}
+
+// print puts a character at the cursor and advances it
+func (s *Screen) print(ch rune) {
+	if s.cursor.charsets[s.cursor.gl] == charsetDECGraphics {
+		if mapped, ok := decGraphics[ch]; ok {
+			ch = mapped
+		}
+	} else if s.cursor.charsets[s.cursor.gl] == charsetUK && ch == '#' {
+		ch = '£'
+	}
+
+	width := runewidth.RuneWidth(ch)
+	if width == 0 {
+		// combining characters aren't supported, so we drop them
+		return
+	}
+
+	if s.cursor.pendingWrap && s.autowrap {
+		s.currentLine().Wrapped = true
+		s.cursor.x = 0
+		s.index()
+	}
+	s.cursor.pendingWrap = false
+
+	if width == 2 && s.cursor.x == s.cols-1 {
+		if !s.autowrap || s.cols < 2 {
+			return
+		}
+		s.currentLine().Cells[s.cursor.x] = blankCell(s.cursor.attr)
+		s.currentLine().Wrapped = true
+		s.cursor.x = 0
+		s.index()
+	}
+
+	line := s.currentLine()
+	if s.insertMode {
+		s.insertBlanks(width)
+	}
+
+	line.Cells[s.cursor.x] = Cell{Ch: ch, Attr: s.cursor.attr}
+	if line.Time.IsZero() {
+		line.Time = s.now
+	}
+	if width == 2 {
+		line.Cells[s.cursor.x+1] = Cell{Ch: continuation, Attr: s.cursor.attr}
+	}
+	s.lastPrinted = ch
+
+	if s.cursor.x+width >= s.cols {
+		s.cursor.x = s.cols - 1
+		s.cursor.pendingWrap = true
+	} else {
+		s.cursor.x += width
+	}
+}
+
+// index moves the cursor down a line, scrolling if it's at the bottom margin
+func (s *Screen) index() {
+	if s.cursor.y == s.scrollBottom {
+		s.scrollUp(1)
+	} else if s.cursor.y < s.rows-1 {
+		s.cursor.y++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	if s.cursor.y == s.scrollTop {
+		s.scrollDown(1)
+	} else if s.cursor.y > 0 {
+		s.cursor.y--
+	}
+}
+
+func (s *Screen) lineFeed() {
+	s.index()
+	if s.newlineMode {
+		s.cursor.x = 0
+	}
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) carriageReturn() {
+	s.cursor.x = 0
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) backspace() {
+	if s.cursor.x > 0 {
+		s.cursor.x--
+	}
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) tab(n int) {
+	for ; n > 0; n-- {
+		x := s.cursor.x + 1
+		for x < s.cols-1 && !s.tabStops[x] {
+			x++
+		}
+		s.cursor.x = clamp(x, 0, s.cols-1)
+	}
+}
+
+func (s *Screen) backTab(n int) {
+	for ; n > 0; n-- {
+		x := s.cursor.x - 1
+		for x > 0 && !s.tabStops[x] {
+			x--
+		}
+		s.cursor.x = clamp(x, 0, s.cols-1)
+	}
+	s.cursor.pendingWrap = false
+}
+
+// scrollUp scrolls the lines within the margins up, adding blank lines at the
+// bottom. Lines leaving the top of the primary screen go into the scrollback.
+func (s *Screen) scrollUp(n int) {
+	n = clamp(n, 0, s.scrollBottom-s.scrollTop+1)
+	for i := 0; i < n; i++ {
+		line := s.lines[s.scrollTop]
+		if s.scrollTop == 0 && !s.altScreen {
+			s.pushScrollback(line)
+		}
+		copy(s.lines[s.scrollTop:s.scrollBottom], s.lines[s.scrollTop+1:s.scrollBottom+1])
+		// reusing the line saves allocating one for every line of output
+		line.clear(0, s.cols, s.cursor.attr)
+		line.Wrapped = false
+		line.Time = time.Time{}
+		s.lines[s.scrollBottom] = line
+	}
+}
+
+func (s *Screen) scrollDown(n int) {
+	n = clamp(n, 0, s.scrollBottom-s.scrollTop+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.scrollTop+1:s.scrollBottom+1], s.lines[s.scrollTop:s.scrollBottom])
+		s.lines[s.scrollTop] = newLine(s.cols, s.cursor.attr)
+	}
+}
+
+func (s *Screen) insertLines(n int) {
+	if s.cursor.y < s.scrollTop || s.cursor.y > s.scrollBottom {
+		return
+	}
+	n = clamp(n, 0, s.scrollBottom-s.cursor.y+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.cursor.y+1:s.scrollBottom+1], s.lines[s.cursor.y:s.scrollBottom])
+		s.lines[s.cursor.y] = newLine(s.cols, s.cursor.attr)
+	}
+	s.cursor.x = 0
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) deleteLines(n int) {
+	if s.cursor.y < s.scrollTop || s.cursor.y > s.scrollBottom {
+		return
+	}
+	n = clamp(n, 0, s.scrollBottom-s.cursor.y+1)
+	for i := 0; i < n; i++ {
+		copy(s.lines[s.cursor.y:s.scrollBottom], s.lines[s.cursor.y+1:s.scrollBottom+1])
+		s.lines[s.scrollBottom] = newLine(s.cols, s.cursor.attr)
+	}
+	s.cursor.x = 0
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) insertBlanks(n int) {
+	line := s.currentLine()
+	n = clamp(n, 0, s.cols-s.cursor.x)
+	copy(line.Cells[s.cursor.x+n:], line.Cells[s.cursor.x:s.cols-n])
+	line.clear(s.cursor.x, s.cursor.x+n, s.cursor.attr)
+}
+
+func (s *Screen) deleteChars(n int) {
+	line := s.currentLine()
+	n = clamp(n, 0, s.cols-s.cursor.x)
+	copy(line.Cells[s.cursor.x:], line.Cells[s.cursor.x+n:])
+	line.clear(s.cols-n, s.cols, s.cursor.attr)
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) eraseChars(n int) {
+	n = clamp(n, 0, s.cols-s.cursor.x)
+	s.currentLine().clear(s.cursor.x, s.cursor.x+n, s.cursor.attr)
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) eraseInLine(mode int) {
+	line := s.currentLine()
+	switch mode {
+	case 0:
+		line.clear(s.cursor.x, s.cols, s.cursor.attr)
+		line.Wrapped = false
+	case 1:
+		line.clear(0, s.cursor.x+1, s.cursor.attr)
+	case 2:
+		line.clear(0, s.cols, s.cursor.attr)
+		line.Wrapped = false
+	}
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) eraseInDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseInLine(0)
+		for y := s.cursor.y + 1; y < s.rows; y++ {
+			s.lines[y] = newLine(s.cols, s.cursor.attr)
+		}
+	case 1:
+		s.eraseInLine(1)
+		for y := 0; y < s.cursor.y; y++ {
+			s.lines[y] = newLine(s.cols, s.cursor.attr)
+		}
+	case 2:
+		for y := 0; y < s.rows; y++ {
+			s.lines[y] = newLine(s.cols, s.cursor.attr)
+		}
+	case 3:
+		s.clearScrollback()
+	}
+}
+
+// moveCursor moves the cursor to the given position, which is relative to the
+// scroll margins when origin mode is on
+func (s *Screen) moveCursor(x, y int) {
+	top, bottom := 0, s.rows-1
+	if s.cursor.originMode {
+		top, bottom = s.scrollTop, s.scrollBottom
+		y += s.scrollTop
+	}
+	s.cursor.x = clamp(x, 0, s.cols-1)
+	s.cursor.y = clamp(y, top, bottom)
+	s.cursor.pendingWrap = false
+}
+
+// moveCursorVertically moves the cursor up or down without leaving the
+// scroll region if it started inside it
+func (s *Screen) moveCursorVertically(n int) {
+	top, bottom := 0, s.rows-1
+	if s.cursor.y >= s.scrollTop && s.cursor.y <= s.scrollBottom {
+		top, bottom = s.scrollTop, s.scrollBottom
+	}
+	s.cursor.y = clamp(s.cursor.y+n, top, bottom)
+	s.cursor.pendingWrap = false
+}
+
+func (s *Screen) setScrollRegion(top, bottom int) {
+	top = clamp(top, 0, s.rows-1)
+	bottom = clamp(bottom, 0, s.rows-1)
+	if top >= bottom {
+		return
+	}
+	s.scrollTop = top
+	s.scrollBottom = bottom
+	s.moveCursor(0, 0)
+}
+
+func (s *Screen) saveCursor() {
+	s.savedCursor = s.cursor
+}
+
+func (s *Screen) restoreCursor() {
+	s.cursor = s.savedCursor
+	s.cursor.x = clamp(s.cursor.x, 0, s.cols-1)
+	s.cursor.y = clamp(s.cursor.y, 0, s.rows-1)
+}
+
+func (s *Screen) enterAltScreen(saveCursor bool, clear bool) {
+	if s.altScreen {
+		return
+	}
+	if saveCursor {
+		s.saveCursor()
+	}
+	s.primaryLines = s.lines
+	s.primaryCursor = s.cursor
+	s.primarySavedCurs = s.savedCursor
+	s.lines = make([]*Line, s.rows)
+	for i := range s.lines {
+		s.lines[i] = newLine(s.cols, DefaultAttr)
+	}
+	s.altScreen = true
+	if clear {
+		s.eraseInDisplay(2)
+	}
+	s.notifyAltScreen(true)
+}
+
+func (s *Screen) exitAltScreen(restoreCursor bool) {
+	if !s.altScreen {
+		return
+	}
+	s.lines = s.primaryLines
+	s.primaryLines = nil
+	s.cursor = s.primaryCursor
+	s.savedCursor = s.primarySavedCurs
+	s.altScreen = false
+	if restoreCursor {
+		s.restoreCursor()
+	}
+	s.notifyAltScreen(false)
+}
+
+func (s *Screen) notifyAltScreen(active bool) {
+	if s.OnAltScreen == nil {
+		return
+	}
+	callback := s.OnAltScreen
+	s.pendingCallbacks = append(s.pendingCallbacks, func() { callback(active) })
+}
+
+func (s *Screen) bell() {
+	if s.OnBell == nil {
+		return
+	}
+	s.pendingCallbacks = append(s.pendingCallbacks, s.OnBell)
+}
+
+func (s *Screen) osc(body string) {
+	params := splitOnce(body, ';')
+	switch params[0] {
+	case "0", "2":
+		if len(params) > 1 {
+			s.title = params[1]
+		}
+		return
+	case "1":
+		// icon name
+		return
+	}
+
+	if s.OnOSC == nil {
+		return
+	}
+	callback := s.OnOSC
+	x, line := s.cursor.x, s.dropped+s.scrollback.len()+s.cursor.y
+	s.pendingCallbacks = append(s.pendingCallbacks, func() { callback(body, x, line) })
+}
+
+// fillWithE fills the screen with 'E's, for DECALN
+func (s *Screen) fillWithE() {
+	for _, line := range s.lines {
+		for i := range line.Cells {
+			line.Cells[i] = Cell{Ch: 'E', Attr: DefaultAttr}
+		}
+	}
+	s.scrollTop = 0
+	s.scrollBottom = s.rows - 1
+	s.moveCursor(0, 0)
+}
+
+var decGraphics = map[rune]rune{
+	'_': ' ', '`': '◆', 'a': '▒', 'b': '␉', 'c': '␌', 'd': '␍', 'e': '␊', 'f': '°',
+	'g': '±', 'h': '␤', 'i': '␋', 'j': '┘', 'k': '┐', 'l': '┌', 'm': '└', 'n': '┼',
+	'o': '⎺', 'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽', 't': '├', 'u': '┤', 'v': '┴',
+	'w': '┬', 'x': '│', 'y': '≤', 'z': '≥', '{': 'π', '|': '≠', '}': '£', '~': '·',
+}
+
+func splitOnce(str string, sep byte) []string {
+	for i := 0; i < len(str); i++ {
+		if str[i] == sep {
+			return []string{str[:i], str[i+1:]}
+		}
+	}
+	return []string{str}
+}
@@ -0,0 +1,151 @@
+package terminal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Spill is a gzipped file that lines go to when they fall out of the
+// scrollback, so that they can still be read back later. Each line is stored
+// as its Time in nanoseconds, a tab, and its text with SGR sequences for its
+// colours and styles.
+type Spill struct {
+	mutex  sync.Mutex
+	path   string
+	file   *os.File
+	writer *gzip.Writer
+	count  int
+	// err is the first error we got writing to the file, after which we stop
+	err error
+}
+
+// NewSpill creates the spill file. It's deleted again by Close.
+func NewSpill(path string) (*Spill, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Spill{
+		path:   path,
+		file:   file,
+		writer: gzip.NewWriter(file),
+	}, nil
+}
+
+func (s *Spill) append(lines []*Line) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, line := range lines {
+		if s.err != nil {
+			return
+		}
+		nanos := int64(0)
+		if !line.Time.IsZero() {
+			nanos = line.Time.UnixNano()
+		}
+		_, s.err = fmt.Fprintf(s.writer, "%d\t%s\n", nanos, line.ANSI())
+		s.count++
+	}
+}
+
+// Len returns the number of lines in the file
+func (s *Spill) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// each reads the first n lines back out of the file and hands them to fn.
+// The file is only locked while what's been written to it is flushed, so
+// lines can go on being appended while it's read.
+func (s *Spill) each(n int, fn func(HistoryLine) error) error {
+	s.mutex.Lock()
+	err := s.err
+	if err == nil {
+		// make everything written so far readable without ending the stream
+		err = s.writer.Flush()
+	}
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	read := 0
+	for read < n && scanner.Scan() {
+		line := HistoryLine{}
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) == 2 {
+			line.Text = fields[1]
+			if nanos, err := strconv.ParseInt(fields[0], 10, 64); err == nil && nanos != 0 {
+				line.Time = time.Unix(0, nanos)
+			}
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+		read++
+	}
+	// the stream hasn't been closed, so the reader will complain once it's
+	// read everything that's been flushed, and whatever's been written since
+	// may be cut off part way through
+	if err := scanner.Err(); err != nil && read < n {
+		return err
+	}
+
+	return nil
+}
+
+// Close closes and deletes the file
+func (s *Spill) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_ = s.writer.Close()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.path)
+}
+
+// stripSGR removes the SGR sequences that Line.ANSI adds
+func stripSGR(str string) string {
+	if !strings.Contains(str, "\x1b[") {
+		return str
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] == 0x1b && i+1 < len(str) && str[i+1] == '[' {
+			end := strings.IndexByte(str[i:], 'm')
+			if end != -1 {
+				i += end
+				continue
+			}
+		}
+		builder.WriteByte(str[i])
+	}
+	return builder.String()
+}
@@ -0,0 +1,528 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newScreen(cols, rows int, input string) *Screen {
+	s := New(cols, rows)
+	_, _ = s.Write([]byte(input))
+	return s
+}
+
+// rows returns the screen's text with trailing empty rows dropped
+func rows(s *Screen) []string {
+	text := s.ScreenText()
+	for len(text) > 0 && text[len(text)-1] == "" {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+func TestPrintAndWrap(t *testing.T) {
+	type scenario struct {
+		name     string
+		cols     int
+		input    string
+		expected []string
+		cursorX  int
+		cursorY  int
+	}
+
+	scenarios := []scenario{
+		{
+			"plain text",
+			10,
+			"hello\r\nworld",
+			[]string{"hello", "world"},
+			5, 1,
+		},
+		{
+			"filling a line doesn't wrap until the next character",
+			5,
+			"abcde",
+			[]string{"abcde"},
+			4, 0,
+		},
+		{
+			"wrapping onto the next line",
+			5,
+			"abcdefg",
+			[]string{"abcde", "fg"},
+			2, 1,
+		},
+		{
+			"carriage return cancels a pending wrap",
+			5,
+			"abcde\rx",
+			[]string{"xbcde"},
+			1, 0,
+		},
+		{
+			"backspace and overwrite",
+			5,
+			"abc\b\bX",
+			[]string{"aXc"},
+			2, 0,
+		},
+		{
+			"tab stops",
+			20,
+			"a\tb\tc",
+			[]string{"a       b       c"},
+			17, 0,
+		},
+		{
+			"wide characters wrap as a whole",
+			5,
+			"abcd世",
+			[]string{"abcd", "世"},
+			2, 1,
+		},
+		{
+			"DEC special graphics",
+			5,
+			"\x1b(0lqk\x1b(Bx",
+			[]string{"┌─┐x"},
+			4, 0,
+		},
+		{
+			"repeat the last character",
+			5,
+			"a\x1b[3b",
+			[]string{"aaaa"},
+			4, 0,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			screen := newScreen(s.cols, 3, s.input)
+			assert.EqualValues(t, s.expected, rows(screen))
+			x, y := screen.Cursor()
+			assert.EqualValues(t, s.cursorX, x)
+			assert.EqualValues(t, s.cursorY, y)
+		})
+	}
+}
+
+func TestTextWithCells(t *testing.T) {
+	s := newScreen(10, 1, "a世b")
+	text, cells := s.Lines(0, 1)[0].TextWithCells()
+	assert.EqualValues(t, "a世b", text)
+	// 世 is three bytes and two cells wide
+	assert.EqualValues(t, []int{0, 1, 1, 1, 3, 4}, cells)
+}
+
+func TestEditing(t *testing.T) {
+	type scenario struct {
+		name     string
+		input    string
+		expected []string
+	}
+
+	scenarios := []scenario{
+		{"erase to end of line", "abcdef\x1b[3G\x1b[K", []string{"ab"}},
+		{"erase to start of line", "abcdef\x1b[3G\x1b[1K", []string{"   def"}},
+		{"erase line", "abcdef\x1b[2K", []string{}},
+		{"erase below", "one\r\ntwo\r\nthree\x1b[2;2H\x1b[J", []string{"one", "t"}},
+		{"erase above", "one\r\ntwo\r\nthree\x1b[2;2H\x1b[1J", []string{"", "  o", "three"}},
+		{"erase display", "one\r\ntwo\x1b[2J", []string{}},
+		{"insert characters", "abcdef\x1b[2G\x1b[2@", []string{"a  bcdef"}},
+		{"delete characters", "abcdef\x1b[2G\x1b[2P", []string{"adef"}},
+		{"erase characters", "abcdef\x1b[2G\x1b[2X", []string{"a  def"}},
+		{"insert mode", "abcdef\x1b[2G\x1b[4hXY\x1b[4l", []string{"aXYbcdef"}},
+		{"insert lines", "one\r\ntwo\r\nthree\x1b[2H\x1b[L", []string{"one", "", "two", "three"}},
+		{"delete lines", "one\r\ntwo\r\nthree\x1b[1H\x1b[M", []string{"two", "three"}},
+		{"cursor movement", "\x1b[3;4Hx\x1b[Ay\x1b[2Dz\x1b[Bw", []string{"", "   zy", "   xw"}},
+		{"save and restore cursor", "ab\x1b7\x1b[3;3Hx\x1b8c", []string{"abc", "", "  x"}},
+		{"screen alignment", "\x1b#8", []string{"EEEEEEEEEE", "EEEEEEEEEE", "EEEEEEEEEE", "EEEEEEEEEE", "EEEEEEEEEE"}},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			assert.EqualValues(t, s.expected, rows(newScreen(10, 5, s.input)))
+		})
+	}
+}
+
+func TestScrolling(t *testing.T) {
+	s := newScreen(10, 3, "one\r\ntwo\r\nthree\r\nfour")
+	assert.EqualValues(t, []string{"two", "three", "four"}, s.ScreenText())
+	assert.EqualValues(t, []string{"one", "two", "three", "four"}, s.Text())
+	assert.EqualValues(t, 3, s.CursorLine())
+
+	// lines scrolled out of a region that doesn't start at the top aren't kept
+	s = newScreen(10, 4, "one\x1b[2;3r\x1b[2Htwo\r\nthree\r\nfour")
+	assert.EqualValues(t, []string{"one", "three", "four", ""}, s.ScreenText())
+	assert.EqualValues(t, 4, s.LineCount())
+
+	s = newScreen(10, 3, "one\r\ntwo\r\nthree\x1b[H\x1bM")
+	assert.EqualValues(t, []string{"", "one", "two"}, s.ScreenText())
+
+	s = newScreen(10, 3, "one\r\ntwo\r\nthree\x1b[2S")
+	assert.EqualValues(t, []string{"three", "", ""}, s.ScreenText())
+
+	s = newScreen(10, 3, "one\r\ntwo\x1b[T")
+	assert.EqualValues(t, []string{"", "one", "two"}, s.ScreenText())
+}
+
+func TestScrollbackLimit(t *testing.T) {
+	s := New(10, 2)
+	s.SetScrollbackLimit(3, 0)
+	for i := 0; i < 10; i++ {
+		_, _ = s.Write([]byte(fmt.Sprintf("%d\r\n", i)))
+	}
+	// lines 0 to 5 have fallen out
+	assert.EqualValues(t, 6, s.FirstLine())
+	assert.EqualValues(t, 11, s.LineCount())
+	assert.EqualValues(t, 10, s.CursorLine())
+	assert.EqualValues(t, []string{"6", "7", "8", "9", ""}, s.Text())
+	assert.EqualValues(t, "7", s.Lines(0, 8)[1].String())
+
+	// a byte limit keeps however many lines fit
+	s.SetScrollbackLimit(0, lineBytes(&Line{Cells: make([]Cell, 1)})*2)
+	assert.EqualValues(t, 7, s.FirstLine())
+
+	// clearing the scrollback keeps the numbering
+	_, _ = s.Write([]byte("\x1b[3J"))
+	assert.EqualValues(t, 9, s.FirstLine())
+	assert.EqualValues(t, 11, s.LineCount())
+
+	// memory doesn't grow however much we write
+	s = New(80, 24)
+	s.SetScrollbackLimit(100, 0)
+	line := []byte(strings.Repeat("x", 80) + "\r\n")
+	for i := 0; i < 100000; i++ {
+		_, _ = s.Write(line)
+	}
+	assert.EqualValues(t, 100, s.scrollback.len())
+	assert.EqualValues(t, 100, len(s.scrollback.lines))
+}
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "scrollback.gz")
+
+	spill, err := NewSpill(path)
+	assert.NoError(t, err)
+
+	s := New(10, 2)
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.clock = func() time.Time { return start }
+	s.SetScrollbackLimit(2, 0)
+	s.SetSpill(spill)
+	_, _ = s.Write([]byte("\x1b[31mred\x1b[m\r\none\r\ntwo\r\nthree\r\nfour"))
+	assert.EqualValues(t, 1, spill.Len())
+
+	lines, first, err := s.History(false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, first)
+	assert.EqualValues(t, []string{"red", "one", "two", "three", "four"}, historyText(lines))
+	// times survive the trip through the file
+	assert.True(t, start.Equal(lines[0].Time))
+
+	lines, _, err = s.History(true)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "\x1b[0m\x1b[38;5;1mred\x1b[0m", lines[0].Text)
+
+	// we can keep writing after reading
+	_, _ = s.Write([]byte("\r\nfive"))
+	lines, _, err = s.History(false)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"red", "one", "two", "three", "four", "five"}, historyText(lines))
+
+	// the screen isn't locked while we go through the lines, and what's
+	// written meanwhile is left out
+	seen := []string{}
+	first, err = s.EachHistoryLine(false, func(line HistoryLine) error {
+		if len(seen) == 0 {
+			_, _ = s.Write([]byte("\r\nsix\r\nseven"))
+		}
+		seen = append(seen, line.Text)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, first)
+	assert.EqualValues(t, []string{"red", "one", "two", "three", "four", "five"}, seen)
+
+	stop := errors.New("stop")
+	_, err = s.EachHistoryLine(false, func(line HistoryLine) error { return stop })
+	assert.Equal(t, stop, err)
+
+	assert.NoError(t, spill.Close())
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+	assert.NoError(t, os.Remove(dir))
+}
+
+func historyText(lines []HistoryLine) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = line.Text
+	}
+	return result
+}
+
+func TestLineTimes(t *testing.T) {
+	s := New(10, 3)
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.clock = func() time.Time { return now }
+	_, _ = s.Write([]byte("one\r\n"))
+
+	now = now.Add(time.Second)
+	// rewriting a line keeps the time it first got output
+	_, _ = s.Write([]byte("\x1b[Hxyz\r\n\r\ntwo"))
+
+	lines := s.Lines(0, 3)
+	assert.EqualValues(t, 12*time.Hour, lines[0].Time.Sub(now.Truncate(24*time.Hour)))
+	assert.True(t, lines[1].Time.IsZero())
+	assert.True(t, now.Equal(lines[2].Time))
+
+	// lines that scroll off the top and get reused start afresh
+	_, _ = s.Write([]byte("\r\n"))
+	assert.True(t, s.Lines(0, 4)[3].Time.IsZero())
+}
+
+func TestOriginMode(t *testing.T) {
+	s := newScreen(10, 5, "\x1b[2;4r\x1b[?6h\x1b[Hx\x1b[10;1Hy\x1b[6n")
+	assert.EqualValues(t, []string{"", "x", "", "y"}, rows(s))
+}
+
+func TestResponses(t *testing.T) {
+	s := New(10, 5)
+	buffer := &bytes.Buffer{}
+	s.SetResponseWriter(buffer)
+
+	_, _ = s.Write([]byte("\x1b[2;3H\x1b[6n\x1b[c\x1b[5n"))
+	assert.EqualValues(t, "\x1b[2;3R\x1b[?1;2c\x1b[0n", buffer.String())
+}
+
+func TestAttributes(t *testing.T) {
+	s := newScreen(20, 2, "\x1b[1;31ma\x1b[22;4;38;5;200mb\x1b[0;48;2;255;0;0mc\x1b[7md\x1b[m")
+	line := s.Lines(0, 1)[0]
+
+	assert.EqualValues(t, Attr{Fg: 1, Bg: DefaultColor, Flags: Bold}, line.Cells[0].Attr)
+	assert.EqualValues(t, Attr{Fg: 200, Bg: DefaultColor, Flags: Underline}, line.Cells[1].Attr)
+	assert.EqualValues(t, Attr{Fg: DefaultColor, Bg: RGBColor(255, 0, 0)}, line.Cells[2].Attr)
+	assert.EqualValues(t, Attr{Fg: DefaultColor, Bg: RGBColor(255, 0, 0), Flags: Reverse}, line.Cells[3].Attr)
+
+	assert.EqualValues(t,
+		"\x1b[0m\x1b[38;5;1m\x1b[1ma"+
+			"\x1b[0m\x1b[38;5;200m\x1b[4mb"+
+			"\x1b[0m\x1b[48;5;196mc"+
+			"\x1b[0m\x1b[48;5;196m\x1b[7md"+
+			"\x1b[0m",
+		line.ANSI(),
+	)
+}
+
+func TestModes(t *testing.T) {
+	s := newScreen(10, 5, "\x1b[?25l\x1b[?1h\x1b[?2004h\x1b[?1004h\x1b[?1002h\x1b[?1006h\x1b]2;my title\a")
+	assert.False(t, s.CursorVisible())
+	assert.True(t, s.AppCursorKeys())
+	assert.True(t, s.BracketedPaste())
+	assert.True(t, s.FocusReporting())
+	mode, encoding := s.Mouse()
+	assert.EqualValues(t, MouseButton, mode)
+	assert.EqualValues(t, MouseEncodingSGR, encoding)
+	assert.EqualValues(t, "my title", s.Title())
+
+	_, _ = s.Write([]byte("\x1b[?25h\x1b[?1l\x1b[?1002l\x1b[?1006l"))
+	assert.True(t, s.CursorVisible())
+	assert.False(t, s.AppCursorKeys())
+	mode, encoding = s.Mouse()
+	assert.EqualValues(t, MouseNone, mode)
+	assert.EqualValues(t, MouseEncodingDefault, encoding)
+}
+
+func TestAltScreen(t *testing.T) {
+	s := New(10, 3)
+	switches := []bool{}
+	s.OnAltScreen = func(active bool) { switches = append(switches, active) }
+
+	_, _ = s.Write([]byte("shell\r\n$ \x1b[?1049h\x1b[Hfull screen"))
+	assert.True(t, s.AltScreen())
+	assert.EqualValues(t, []string{"full scree", "n", ""}, s.ScreenText())
+
+	_, _ = s.Write([]byte("\x1b[?1049l"))
+	assert.False(t, s.AltScreen())
+	assert.EqualValues(t, []string{"shell", "$", ""}, s.ScreenText())
+	x, y := s.Cursor()
+	assert.EqualValues(t, 2, x)
+	assert.EqualValues(t, 1, y)
+	assert.EqualValues(t, []bool{true, false}, switches)
+}
+
+func TestOSC(t *testing.T) {
+	s := New(20, 3)
+	type osc struct {
+		body string
+		x    int
+		line int
+	}
+	received := []osc{}
+	s.OnOSC = func(body string, x int, line int) {
+		received = append(received, osc{body, x, line})
+	}
+	bells := 0
+	s.OnBell = func() { bells++ }
+
+	// sequences and UTF-8 characters can be split across writes
+	for _, chunk := range []string{"a\r\n$ \x1b]13", "3;B\x07ls \xe4", "\xb8\x96\x1b]7;file://host/tmp\x1b", "\\\a"} {
+		_, _ = s.Write([]byte(chunk))
+	}
+
+	assert.EqualValues(t, []osc{{"133;B", 2, 1}, {"7;file://host/tmp", 7, 1}}, received)
+	assert.EqualValues(t, []string{"a", "$ ls 世", ""}, s.ScreenText())
+	assert.EqualValues(t, 1, bells)
+}
+
+func TestResize(t *testing.T) {
+	s := newScreen(10, 4, "one\r\ntwo\r\nthree\r\nfour")
+
+	s.Resize(5, 2)
+	assert.EqualValues(t, []string{"three", "four"}, s.ScreenText())
+	assert.EqualValues(t, []string{"one", "two", "three", "four"}, s.Text())
+
+	s.Resize(5, 3)
+	assert.EqualValues(t, []string{"two", "three", "four"}, s.ScreenText())
+	x, y := s.Cursor()
+	assert.EqualValues(t, 4, x)
+	assert.EqualValues(t, 2, y)
+
+	// blank lines below the cursor go first
+	s = newScreen(10, 4, "one")
+	s.Resize(10, 2)
+	assert.EqualValues(t, []string{"one", ""}, s.ScreenText())
+	assert.EqualValues(t, 2, s.LineCount())
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// The fixtures were recorded from real programs running in an 80x24 pty with
+// TERM=xterm-256color
+
+func TestVimFixture(t *testing.T) {
+	s := newScreen(80, 24, string(readFixture(t, "vim.raw")))
+
+	expected := []string{"first line", "second line", "third line", "hello from vim"}
+	for i := 4; i < 23; i++ {
+		expected = append(expected, "~")
+	}
+	expected = append(expected, "")
+
+	assert.True(t, s.AltScreen())
+	assert.EqualValues(t, expected, s.ScreenText())
+	x, y := s.Cursor()
+	assert.EqualValues(t, 13, x)
+	assert.EqualValues(t, 3, y)
+	assert.True(t, s.BracketedPaste())
+	assert.True(t, s.FocusReporting())
+
+	tilde := s.Lines(4, 5)[0].Cells[0]
+	assert.EqualValues(t, Color(12), tilde.Attr.Fg)
+}
+
+func TestLessFixture(t *testing.T) {
+	content := readFixture(t, "less.raw")
+	exit := bytes.Index(content, []byte("\x1b[?1049l"))
+	if exit == -1 {
+		t.Fatal("expected less to leave the alternate screen")
+	}
+
+	s := newScreen(80, 24, string(content[:exit]))
+	expected := []string{}
+	for i := 24; i <= 46; i++ {
+		expected = append(expected, fmt.Sprintf("line %d", i))
+	}
+	// less clears its prompt when it quits
+	expected = append(expected, "")
+	assert.True(t, s.AltScreen())
+	assert.EqualValues(t, expected, s.ScreenText())
+	x, y := s.Cursor()
+	assert.EqualValues(t, 0, x)
+	assert.EqualValues(t, 23, y)
+
+	_, _ = s.Write(content[exit:])
+	assert.False(t, s.AltScreen())
+	assert.EqualValues(t, []string{"before", "after"}, rows(s))
+	assert.EqualValues(t, 24, s.LineCount())
+}
+
+// readline edits the line in place: the second command is the first brought
+// back with the up arrow, with the space before the 7 deleted and an 8 typed
+func TestPythonFixture(t *testing.T) {
+	s := newScreen(80, 24, string(readFixture(t, "python.raw")))
+
+	assert.EqualValues(t, []string{
+		`>>> print(6 * 7)`,
+		`42`,
+		`>>> print(6 *87)`,
+		`522`,
+		`>>> for i in range(3): print("row", i)`,
+		`...`,
+		`row 0`,
+		`row 1`,
+		`row 2`,
+		`>>> print("\033[1;31mred\033[0m plain")`,
+		`red plain`,
+		`>>> 1/0`,
+		`Traceback (most recent call last):`,
+		`  File "<stdin>", line 1, in <module>`,
+		`ZeroDivisionError: division by zero`,
+		`>>> exit()`,
+	}, rows(s))
+
+	red := s.Lines(10, 11)[0]
+	assert.EqualValues(t, Attr{Fg: 1, Bg: DefaultColor, Flags: Bold}, red.Cells[0].Attr)
+	assert.EqualValues(t, DefaultAttr, red.Cells[4].Attr)
+}
+
+// top stands in for htop, which wasn't installed where the fixtures were
+// recorded. It redraws with absolute cursor movement, charset switches and
+// reverse video, as htop does.
+func TestTopFixture(t *testing.T) {
+	s := newScreen(80, 24, string(readFixture(t, "top.raw")))
+	text := s.ScreenText()
+
+	assert.False(t, s.AltScreen())
+	assert.EqualValues(t, 24, len(text))
+	assert.True(t, strings.HasPrefix(text[0], "top - "))
+	assert.True(t, strings.HasPrefix(text[1], "Tasks:"))
+	assert.True(t, strings.HasPrefix(strings.TrimSpace(text[6]), "PID USER"))
+	header := s.Lines(6, 7)[0]
+	assert.EqualValues(t, Reverse, header.Cells[0].Attr.Flags)
+	assert.EqualValues(t, Reverse, header.Cells[75].Attr.Flags)
+	assert.True(t, strings.HasPrefix(strings.TrimSpace(text[7]), "1 root"))
+	assert.False(t, s.CursorVisible())
+}
+
+func BenchmarkWrite(b *testing.B) {
+	chunk := []byte(strings.Repeat("y\r\n", 16*1024))
+	s := New(80, 24)
+	s.SetScrollbackLimit(10000, 0)
+	b.SetBytes(int64(len(chunk)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = s.Write(chunk)
+	}
+}
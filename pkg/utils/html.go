@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the standard 16 colours, roughly as xterm draws them
+var basicColors = []string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+var escapeSequenceRegex = regexp.MustCompile(`\x1b\[[0-9:;<=>?]*[ -/]*[@-~]|\x1b\][^\a\x1b]*(\a|\x1b\\)|\x1b[()][0-9A-Za-z]|\x1b[=>78]`)
+
+type htmlStyle struct {
+	fg, bg                      string
+	bold, italic, underline, rv bool
+}
+
+func (s htmlStyle) css() string {
+	fg, bg := s.fg, s.bg
+	if s.rv {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "#000000"
+		}
+		if bg == "" {
+			bg = "#e5e5e5"
+		}
+	}
+
+	props := []string{}
+	if fg != "" {
+		props = append(props, "color:"+fg)
+	}
+	if bg != "" {
+		props = append(props, "background-color:"+bg)
+	}
+	if s.bold {
+		props = append(props, "font-weight:bold")
+	}
+	if s.italic {
+		props = append(props, "font-style:italic")
+	}
+	if s.underline {
+		props = append(props, "text-decoration:underline")
+	}
+	return strings.Join(props, ";")
+}
+
+// Color256ToHex returns the hex code of a colour from the xterm 256 colour palette
+func Color256ToHex(n int) string {
+	switch {
+	case n < 16:
+		return basicColors[n]
+	case n < 232:
+		n -= 16
+		levels := []int{0, 95, 135, 175, 215, 255}
+		return fmt.Sprintf("#%02x%02x%02x", levels[n/36], levels[(n/6)%6], levels[n%6])
+	default:
+		grey := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", grey, grey, grey)
+	}
+}
+
+// applySGR updates the style according to the parameters of an SGR sequence
+func (s *htmlStyle) applySGR(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			*s = htmlStyle{}
+		case p == 1:
+			s.bold = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 7:
+			s.rv = true
+		case p == 22:
+			s.bold = false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p == 27:
+			s.rv = false
+		case p >= 30 && p <= 37:
+			s.fg = basicColors[p-30]
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = basicColors[p-40]
+		case p == 49:
+			s.bg = ""
+		case p >= 90 && p <= 97:
+			s.fg = basicColors[p-90+8]
+		case p >= 100 && p <= 107:
+			s.bg = basicColors[p-100+8]
+		case p == 38 || p == 48:
+			color := ""
+			if i+2 < len(params) && params[i+1] == 5 {
+				color = Color256ToHex(params[i+2] & 0xff)
+				i += 2
+			} else if i+4 < len(params) && params[i+1] == 2 {
+				color = fmt.Sprintf("#%02x%02x%02x", params[i+2]&0xff, params[i+3]&0xff, params[i+4]&0xff)
+				i += 4
+			}
+			if p == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		}
+	}
+}
+
+// parseSGRParams reads the parameters of an SGR sequence. Parameters can have
+// colon-separated parts, as in '38:2::255:128:0' for a truecolor foreground,
+// which we turn into the semicolon-separated form applySGR understands.
+func parseSGRParams(str string) []int {
+	params := []int{}
+	for _, param := range strings.Split(str, ";") {
+		parts := strings.Split(param, ":")
+		values := make([]int, len(parts))
+		for i, part := range parts {
+			values[i], _ = strconv.Atoi(part)
+		}
+
+		switch {
+		case len(values) == 1:
+			params = append(params, values[0])
+		case values[0] == 38 || values[0] == 48:
+			// the RGB form can have a colour space before the colours
+			if values[1] == 2 && len(values) >= 6 {
+				values = append(values[:2], values[3:6]...)
+			}
+			params = append(params, values...)
+		case values[0] == 4:
+			// the kind of underline, where 0 is none
+			if values[1] == 0 {
+				params = append(params, 24)
+			} else {
+				params = append(params, 4)
+			}
+		case values[0] == 58:
+			// the underline colour, which we don't show
+		default:
+			params = append(params, values[0])
+		}
+	}
+	return params
+}
+
+// AnsiToHTML converts text containing ANSI colour codes into HTML spans that
+// keep the colours. Any other escape sequences are dropped.
+func AnsiToHTML(str string) string {
+	str = strings.Replace(str, "\r\n", "\n", -1)
+
+	var builder strings.Builder
+	style := htmlStyle{}
+	spanOpen := false
+
+	writeText := func(text string) {
+		text = strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\t' || r >= ' ' {
+				return r
+			}
+			return -1
+		}, text)
+		builder.WriteString(html.EscapeString(text))
+	}
+
+	last := 0
+	for _, loc := range escapeSequenceRegex.FindAllStringIndex(str, -1) {
+		writeText(str[last:loc[0]])
+		last = loc[1]
+
+		sequence := str[loc[0]:loc[1]]
+		if !strings.HasPrefix(sequence, "\x1b[") || !strings.HasSuffix(sequence, "m") {
+			continue
+		}
+
+		style.applySGR(parseSGRParams(sequence[2 : len(sequence)-1]))
+
+		if spanOpen {
+			builder.WriteString("</span>")
+			spanOpen = false
+		}
+		if css := style.css(); css != "" {
+			builder.WriteString(`<span style="` + css + `">`)
+			spanOpen = true
+		}
+	}
+	writeText(str[last:])
+
+	if spanOpen {
+		builder.WriteString("</span>")
+	}
+
+	return builder.String()
+}
+
+// AnsiToHTMLDocument wraps the output of AnsiToHTML in a self-contained page
+func AnsiToHTMLDocument(title string, str string) string {
+	return HTMLDocumentHeader(title) + AnsiToHTML(str) + HTMLDocumentFooter
+}
+
+// HTMLDocumentHeader starts the page AnsiToHTMLDocument makes, for when the
+// text is converted a bit at a time. HTMLDocumentFooter ends it.
+func HTMLDocumentHeader(title string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { background-color: #000000; color: #e5e5e5; }
+pre { font-family: Menlo, Consolas, "DejaVu Sans Mono", monospace; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<pre>`, html.EscapeString(title))
+}
+
+// HTMLDocumentFooter ends the page HTMLDocumentHeader starts
+const HTMLDocumentFooter = `</pre>
+</body>
+</html>
+`
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnsiToHTML(t *testing.T) {
+	type scenario struct {
+		name     string
+		input    string
+		expected string
+	}
+
+	scenarios := []scenario{
+		{
+			"plain text is escaped",
+			`<b>&"x"`,
+			"&lt;b&gt;&amp;&#34;x&#34;",
+		},
+		{
+			"a colour is reset",
+			"\x1b[31mred\x1b[0m plain",
+			`<span style="color:#cd0000">red</span> plain`,
+		},
+		{
+			"a reset with no parameters",
+			"\x1b[32mgreen\x1b[mplain",
+			`<span style="color:#00cd00">green</span>plain`,
+		},
+		{
+			"text in a span is escaped",
+			"\x1b[31m<&>",
+			`<span style="color:#cd0000">&lt;&amp;&gt;</span>`,
+		},
+		{
+			"256 colours",
+			"\x1b[38;5;196;48;5;244mx",
+			`<span style="color:#ff0000;background-color:#808080">x</span>`,
+		},
+		{
+			"truecolor",
+			"\x1b[38;2;1;2;3mx",
+			`<span style="color:#010203">x</span>`,
+		},
+		{
+			"truecolor with colons and a colour space",
+			"\x1b[38:2::1:2:3mx",
+			`<span style="color:#010203">x</span>`,
+		},
+		{
+			"truecolor with colons and no colour space",
+			"\x1b[48:2:1:2:3mx",
+			`<span style="background-color:#010203">x</span>`,
+		},
+		{
+			"256 colours with colons, among other parameters",
+			"\x1b[1;38:5:196mx",
+			`<span style="color:#ff0000;font-weight:bold">x</span>`,
+		},
+		{
+			"curly underlines, underline colours and no underline",
+			"\x1b[4:3;58:2::9:9:9ma\x1b[4:0mb",
+			`<span style="text-decoration:underline">a</span>b`,
+		},
+		{
+			"attributes are added and taken away one at a time",
+			"\x1b[1mbold \x1b[4;34mboth\x1b[22m under\x1b[0m",
+			`<span style="font-weight:bold">bold </span>` +
+				`<span style="color:#0000ee;font-weight:bold;text-decoration:underline">both</span>` +
+				`<span style="color:#0000ee;text-decoration:underline"> under</span>`,
+		},
+		{
+			"reverse video with the default colours",
+			"\x1b[7mx",
+			`<span style="color:#000000;background-color:#e5e5e5">x</span>`,
+		},
+		{
+			"other escape sequences and control characters are dropped",
+			"a\x1b[2Jb\x1b]0;title\x07c\x00\r\nd",
+			"abc\nd",
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			assert.Equal(t, s.expected, AnsiToHTML(s.input))
+		})
+	}
+}
+
+func TestAnsiToHTMLDocument(t *testing.T) {
+	document := AnsiToHTMLDocument("a & b", "\x1b[31mx")
+	assert.Contains(t, document, "<title>a &amp; b</title>")
+	assert.Contains(t, document, `<pre><span style="color:#cd0000">x</span></pre>`)
+}
+
+func TestColor256ToHex(t *testing.T) {
+	assert.Equal(t, "#cd0000", Color256ToHex(1))
+	assert.Equal(t, "#000000", Color256ToHex(16))
+	assert.Equal(t, "#ffffff", Color256ToHex(231))
+	assert.Equal(t, "#080808", Color256ToHex(232))
+	assert.Equal(t, "#eeeeee", Color256ToHex(255))
+}